@@ -0,0 +1,11 @@
+package config
+
+type AdminConfig struct {
+	APIToken string
+}
+
+func GetAdminConfig() *AdminConfig {
+	return &AdminConfig{
+		APIToken: Getenv("ADMIN_API_TOKEN"),
+	}
+}
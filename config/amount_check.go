@@ -0,0 +1,59 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/kytapay/webhook-v2/models"
+)
+
+// Policies for a payment callback whose amount differs from the order amount by more than the tolerance
+const (
+	// AmountPolicyReject holds the callback for review, nothing is settled
+	AmountPolicyReject = "reject"
+	// AmountPolicyFlag settles the order amount and flags the transaction for reconciliation
+	AmountPolicyFlag = "flag"
+	// AmountPolicyPartial settles the amount that was actually paid and flags the transaction
+	AmountPolicyPartial = "partial"
+)
+
+// AmountCheckConfig controls the comparison of callback amounts with the stored order amount
+type AmountCheckConfig struct {
+	Policy    string
+	Tolerance models.Money
+}
+
+// GetAmountCheckConfig reads AMOUNT_MISMATCH_POLICY and AMOUNT_MISMATCH_TOLERANCE
+// Invalid values fall back to the strictest setting (reject, no tolerance); ValidateConfig reports them
+func GetAmountCheckConfig() *AmountCheckConfig {
+	cfg, err := loadAmountCheck()
+	if err != nil {
+		return &AmountCheckConfig{Policy: AmountPolicyReject}
+	}
+	return cfg
+}
+
+func loadAmountCheck() (*AmountCheckConfig, error) {
+	cfg := &AmountCheckConfig{Policy: strings.ToLower(strings.TrimSpace(Getenv("AMOUNT_MISMATCH_POLICY")))}
+	var errs []error
+
+	switch cfg.Policy {
+	case "":
+		cfg.Policy = AmountPolicyReject
+	case AmountPolicyReject, AmountPolicyFlag, AmountPolicyPartial:
+	default:
+		errs = append(errs, fmt.Errorf("AMOUNT_MISMATCH_POLICY %q must be %s, %s or %s", cfg.Policy, AmountPolicyReject, AmountPolicyFlag, AmountPolicyPartial))
+	}
+
+	if value := strings.TrimSpace(Getenv("AMOUNT_MISMATCH_TOLERANCE")); value != "" {
+		tolerance, err := models.ParseMoney(value)
+		if err != nil || tolerance < 0 {
+			errs = append(errs, fmt.Errorf("AMOUNT_MISMATCH_TOLERANCE %q is not a non-negative rupiah amount", value))
+		} else {
+			cfg.Tolerance = tolerance
+		}
+	}
+
+	return cfg, errors.Join(errs...)
+}
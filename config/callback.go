@@ -0,0 +1,22 @@
+package config
+
+import "time"
+
+type CallbackRetryConfig struct {
+	MaxRetries   int
+	BaseDelay    time.Duration
+	MaxDelay     time.Duration
+	PollInterval time.Duration
+	// LeaseTimeout is how long a claimed retry may stay in Retrying before another run claims it again
+	LeaseTimeout time.Duration
+}
+
+func GetCallbackRetryConfig() *CallbackRetryConfig {
+	return &CallbackRetryConfig{
+		MaxRetries:   getEnvInt("CALLBACK_RETRY_MAX_RETRIES", 5),
+		BaseDelay:    getEnvDuration("CALLBACK_RETRY_BASE_DELAY", time.Minute),
+		MaxDelay:     getEnvDuration("CALLBACK_RETRY_MAX_DELAY", 6*time.Hour),
+		PollInterval: getEnvDuration("CALLBACK_RETRY_POLL_INTERVAL", 30*time.Second),
+		LeaseTimeout: getEnvDuration("CALLBACK_RETRY_LEASE_TIMEOUT", 5*time.Minute),
+	}
+}
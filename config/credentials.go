@@ -0,0 +1,116 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Credential slots; "next" is configured ahead of a rotation and accepted alongside "current"
+const (
+	CredentialCurrent = "current"
+	CredentialNext    = "next"
+)
+
+// providerMu guards the cached provider configs swapped by ReloadProviderCredentials
+var providerMu sync.RWMutex
+
+// ReloadProviderCredentials re-reads provider credentials, key files and the status mapping from the environment
+// A provider whose new config fails to load keeps its previous config
+func ReloadProviderCredentials() error {
+	linkQu, linkQuErr := loadLinkQuConfig()
+	pakaiLink, pakaiLinkErr := loadPakaiLinkConfig()
+	xendit, xenditErr := loadXenditConfig()
+	midtrans, midtransErr := loadMidtransConfig()
+	snap, snapErr := loadSnapPartners()
+	mapping, mappingErr := loadStatusMapping()
+
+	providerMu.Lock()
+	if linkQuErr == nil {
+		linkQuConfig = linkQu
+	}
+	if pakaiLinkErr == nil {
+		pakaiLinkConfig = pakaiLink
+	}
+	if xenditErr == nil {
+		xenditConfig = xendit
+	}
+	if midtransErr == nil {
+		midtransConfig = midtrans
+	}
+	if snapErr == nil {
+		snapPartners = snap
+	}
+	if mappingErr == nil {
+		statusMapping = mapping
+	}
+	providerMu.Unlock()
+
+	var errs []error
+	if linkQuErr != nil {
+		errs = append(errs, fmt.Errorf("linkqu: %w", linkQuErr))
+	}
+	if pakaiLinkErr != nil {
+		errs = append(errs, fmt.Errorf("pakailink: %w", pakaiLinkErr))
+	}
+	if xenditErr != nil {
+		errs = append(errs, fmt.Errorf("xendit: %w", xenditErr))
+	}
+	if midtransErr != nil {
+		errs = append(errs, fmt.Errorf("midtrans: %w", midtransErr))
+	}
+	if snapErr != nil {
+		errs = append(errs, fmt.Errorf("snap: %w", snapErr))
+	}
+	if mappingErr != nil {
+		errs = append(errs, fmt.Errorf("status mapping: %w", mappingErr))
+	}
+	return errors.Join(errs...)
+}
+
+// CredentialFiles lists the key files referenced by the active provider configs and the status mapping file
+func CredentialFiles() []string {
+	var files []string
+	for _, credential := range GetPakaiLinkConfig().Credentials {
+		if credential.RSAPublicKeyPath != "" {
+			files = append(files, credential.RSAPublicKeyPath)
+		}
+	}
+	for _, partner := range GetSnapPartners() {
+		for _, credential := range partner.Credentials {
+			if credential.RSAPublicKeyPath != "" {
+				files = append(files, credential.RSAPublicKeyPath)
+			}
+		}
+	}
+	if path := GetStatusMappingFile(); path != "" {
+		files = append(files, path)
+	}
+	return files
+}
+
+// GetCredentialReloadInterval is how often key files and .env are checked for changes
+func GetCredentialReloadInterval() time.Duration {
+	return getEnvDuration("CREDENTIAL_RELOAD_INTERVAL", 30*time.Second)
+}
+
+// credentialActive reports whether a credential slot with the given expiry can still be used
+func credentialActive(expiresAt *time.Time, now time.Time) bool {
+	return expiresAt == nil || now.Before(*expiresAt)
+}
+
+// getEnvTime reads an optional RFC 3339 time from env
+func getEnvTime(key string) (*time.Time, error) {
+	value := strings.TrimSpace(Getenv(key))
+	if value == "" {
+		return nil, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, fmt.Errorf("%s: invalid RFC 3339 time %q", key, value)
+	}
+	return &t, nil
+}
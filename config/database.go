@@ -4,18 +4,17 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
-	"os"
 
 	_ "github.com/go-sql-driver/mysql"
 )
 
 // InitDB initializes database connection
 func InitDB() (*sql.DB, error) {
-	dbHost := os.Getenv("DB_HOST")
-	dbPort := os.Getenv("DB_PORT")
-	dbUser := os.Getenv("DB_USER")
-	dbPassword := os.Getenv("DB_PASSWORD")
-	dbName := os.Getenv("DB_NAME")
+	dbHost := Getenv("DB_HOST")
+	dbPort := Getenv("DB_PORT")
+	dbUser := Getenv("DB_USER")
+	dbPassword := Getenv("DB_PASSWORD")
+	dbName := Getenv("DB_NAME")
 
 	if dbPort == "" {
 		dbPort = "3306"
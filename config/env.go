@@ -0,0 +1,68 @@
+package config
+
+import (
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/joho/godotenv"
+)
+
+var (
+	envMu sync.RWMutex
+	// processEnv holds the variables set in the process environment before any .env was read
+	processEnv map[string]bool
+	// dotenv holds the values of the last .env read; the configuration is built from it,
+	// so a variable removed from .env is also removed from the configuration on reload
+	dotenv map[string]string
+)
+
+// LoadEnvFile reads a dotenv file into the environment the configuration is built from
+// Process environment variables take precedence over the file (like godotenv.Load); when the file
+// cannot be read the previous values are kept
+func LoadEnvFile(file string) error {
+	envMu.Lock()
+	defer envMu.Unlock()
+
+	if processEnv == nil {
+		processEnv = make(map[string]bool)
+		for _, entry := range os.Environ() {
+			processEnv[strings.SplitN(entry, "=", 2)[0]] = true
+		}
+	}
+
+	values, err := godotenv.Read(file)
+	if err != nil {
+		return err
+	}
+	dotenv = values
+	return nil
+}
+
+// Getenv returns a configuration variable from the process environment or the last .env read
+func Getenv(key string) string {
+	envMu.RLock()
+	defer envMu.RUnlock()
+
+	if processEnv == nil || processEnv[key] {
+		return os.Getenv(key)
+	}
+	return dotenv[key]
+}
+
+// ShadowedEnvKeys lists the .env variables that are also set in the process environment (e.g. docker-compose
+// env_file or systemd Environment); the process value wins, so editing them in .env has no effect until restart
+func ShadowedEnvKeys() []string {
+	envMu.RLock()
+	defer envMu.RUnlock()
+
+	var keys []string
+	for key := range dotenv {
+		if processEnv[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -1,16 +1,100 @@
 package config
 
-import "os"
+import (
+	"errors"
+	"log"
+	"time"
+)
 
-type LinkQuConfig struct {
+// LinkQuCredential is one accepted client-id / client-secret pair
+type LinkQuCredential struct {
+	Name         string
 	ClientID     string
 	ClientSecret string
+	// ClientSecretHash is a salted hash (see helpers.HashSecret); used instead of ClientSecret when set
+	ClientSecretHash string
+	ExpiresAt        *time.Time
+}
+
+type LinkQuConfig struct {
+	// Credentials holds the current pair and, during a rotation, the next one
+	Credentials []LinkQuCredential
+	AllowedIPs  []string
 }
 
+var linkQuConfig *LinkQuConfig
+
 func GetLinkQuConfig() *LinkQuConfig {
-	return &LinkQuConfig{
-		ClientID:     os.Getenv("LINKQU_CLIENT_ID"),
-		ClientSecret: os.Getenv("LINKQU_CLIENT_SECRET"),
+	providerMu.RLock()
+	config := linkQuConfig
+	providerMu.RUnlock()
+	if config != nil {
+		return config
 	}
+
+	providerMu.Lock()
+	defer providerMu.Unlock()
+	if linkQuConfig == nil {
+		config, err := loadLinkQuConfig()
+		if err != nil {
+			log.Printf("LinkQu config: %v", err)
+		}
+		linkQuConfig = config
+	}
+	return linkQuConfig
 }
 
+// ActiveCredentials returns the configured, unexpired credentials
+func (c *LinkQuConfig) ActiveCredentials(now time.Time) []LinkQuCredential {
+	var active []LinkQuCredential
+	for _, credential := range c.Credentials {
+		if credentialActive(credential.ExpiresAt, now) {
+			active = append(active, credential)
+		}
+	}
+	return active
+}
+
+// loadLinkQuConfig reads LINKQU_* (current) and LINKQU_NEXT_* (next) credentials from env
+// The next slot reuses the current client id when LINKQU_NEXT_CLIENT_ID is empty
+func loadLinkQuConfig() (*LinkQuConfig, error) {
+	config := &LinkQuConfig{
+		AllowedIPs: getEnvList("LINKQU_ALLOWED_IPS"),
+	}
+	var errs []error
+
+	current := LinkQuCredential{
+		Name:             CredentialCurrent,
+		ClientID:         Getenv("LINKQU_CLIENT_ID"),
+		ClientSecret:     Getenv("LINKQU_CLIENT_SECRET"),
+		ClientSecretHash: Getenv("LINKQU_CLIENT_SECRET_HASH"),
+	}
+	expiresAt, err := getEnvTime("LINKQU_EXPIRES_AT")
+	if err != nil {
+		errs = append(errs, err)
+	}
+	current.ExpiresAt = expiresAt
+
+	next := LinkQuCredential{
+		Name:             CredentialNext,
+		ClientID:         Getenv("LINKQU_NEXT_CLIENT_ID"),
+		ClientSecret:     Getenv("LINKQU_NEXT_CLIENT_SECRET"),
+		ClientSecretHash: Getenv("LINKQU_NEXT_CLIENT_SECRET_HASH"),
+	}
+	if next.ClientID == "" {
+		next.ClientID = current.ClientID
+	}
+	expiresAt, err = getEnvTime("LINKQU_NEXT_EXPIRES_AT")
+	if err != nil {
+		errs = append(errs, err)
+	}
+	next.ExpiresAt = expiresAt
+
+	for _, credential := range []LinkQuCredential{current, next} {
+		if credential.ClientID != "" && (credential.ClientSecret != "" || credential.ClientSecretHash != "") {
+			config.Credentials = append(config.Credentials, credential)
+		}
+	}
+
+	return config, errors.Join(errs...)
+}
@@ -0,0 +1,83 @@
+package config
+
+import (
+	"errors"
+	"log"
+	"time"
+)
+
+// MidtransCredential is one accepted server key, used to verify the notification signature_key
+type MidtransCredential struct {
+	Name      string
+	ServerKey string
+	ExpiresAt *time.Time
+}
+
+type MidtransConfig struct {
+	// Credentials holds the current server key and, during a rotation, the next one
+	Credentials []MidtransCredential
+	AllowedIPs  []string
+}
+
+var midtransConfig *MidtransConfig
+
+func GetMidtransConfig() *MidtransConfig {
+	providerMu.RLock()
+	config := midtransConfig
+	providerMu.RUnlock()
+	if config != nil {
+		return config
+	}
+
+	providerMu.Lock()
+	defer providerMu.Unlock()
+	if midtransConfig == nil {
+		config, err := loadMidtransConfig()
+		if err != nil {
+			log.Printf("Midtrans config: %v", err)
+		}
+		midtransConfig = config
+	}
+	return midtransConfig
+}
+
+// ActiveCredentials returns the configured, unexpired credentials
+func (c *MidtransConfig) ActiveCredentials(now time.Time) []MidtransCredential {
+	var active []MidtransCredential
+	for _, credential := range c.Credentials {
+		if credentialActive(credential.ExpiresAt, now) {
+			active = append(active, credential)
+		}
+	}
+	return active
+}
+
+// loadMidtransConfig reads MIDTRANS_* (current) and MIDTRANS_NEXT_* (next) server keys from env
+func loadMidtransConfig() (*MidtransConfig, error) {
+	config := &MidtransConfig{
+		AllowedIPs: getEnvList("MIDTRANS_ALLOWED_IPS"),
+	}
+	var errs []error
+
+	for _, slot := range []struct{ name, prefix string }{
+		{CredentialCurrent, "MIDTRANS_"},
+		{CredentialNext, "MIDTRANS_NEXT_"},
+	} {
+		credential := MidtransCredential{
+			Name:      slot.name,
+			ServerKey: Getenv(slot.prefix + "SERVER_KEY"),
+		}
+
+		expiresAt, err := getEnvTime(slot.prefix + "EXPIRES_AT")
+		if err != nil {
+			errs = append(errs, err)
+		}
+		credential.ExpiresAt = expiresAt
+
+		if credential.ServerKey != "" {
+			config.Credentials = append(config.Credentials, credential)
+		}
+	}
+
+	return config, errors.Join(errs...)
+}
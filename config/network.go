@@ -0,0 +1,17 @@
+package config
+
+// NetworkConfig describes the proxies in front of the service
+type NetworkConfig struct {
+	// TrustedProxies may set X-Forwarded-For / X-Real-IP; the shipped nginx config runs on the same host
+	TrustedProxies []string
+}
+
+func GetNetworkConfig() *NetworkConfig {
+	cfg := &NetworkConfig{
+		TrustedProxies: getEnvList("TRUSTED_PROXIES"),
+	}
+	if len(cfg.TrustedProxies) == 0 {
+		cfg.TrustedProxies = []string{"127.0.0.1", "::1"}
+	}
+	return cfg
+}
@@ -4,59 +4,149 @@ import (
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
 	"os"
+	"time"
 )
 
-type PakaiLinkConfig struct {
-	ClientSecret    string
-	RSAPublicKey    *rsa.PublicKey
+// PakaiLinkCredential is one accepted set of PakaiLink verification material
+type PakaiLinkCredential struct {
+	Name             string
+	ClientSecret     string
+	RSAPublicKey     *rsa.PublicKey
 	RSAPublicKeyPath string
+	// CallbackToken is the fallback for callbacks sent without X-SIGNATURE / X-TIMESTAMP
+	CallbackToken string
+	ExpiresAt     *time.Time
+}
+
+type PakaiLinkConfig struct {
+	// Credentials holds the current set and, during a rotation, the next one
+	Credentials         []PakaiLinkCredential
+	CallbackTokenHeader string
+	AllowedIPs          []string
 }
 
 var pakaiLinkConfig *PakaiLinkConfig
 
 func GetPakaiLinkConfig() *PakaiLinkConfig {
+	providerMu.RLock()
+	config := pakaiLinkConfig
+	providerMu.RUnlock()
+	if config != nil {
+		return config
+	}
+
+	providerMu.Lock()
+	defer providerMu.Unlock()
 	if pakaiLinkConfig == nil {
-		config := &PakaiLinkConfig{
-			ClientSecret:     os.Getenv("PAKAILINK_CLIENT_SECRET"),
-			RSAPublicKeyPath: os.Getenv("PAKAILINK_RSA_PUBLIC_KEY_PATH"),
+		config, err := loadPakaiLinkConfig()
+		if err != nil {
+			log.Printf("PakaiLink config: %v", err)
+		}
+		pakaiLinkConfig = config
+	}
+	return pakaiLinkConfig
+}
+
+// ActiveCredentials returns the configured, unexpired credentials
+func (c *PakaiLinkConfig) ActiveCredentials(now time.Time) []PakaiLinkCredential {
+	var active []PakaiLinkCredential
+	for _, credential := range c.Credentials {
+		if credentialActive(credential.ExpiresAt, now) {
+			active = append(active, credential)
+		}
+	}
+	return active
+}
+
+// loadPakaiLinkConfig reads PAKAILINK_* (current) and PAKAILINK_NEXT_* (next) credentials from env
+// and loads their RSA public keys from disk
+func loadPakaiLinkConfig() (*PakaiLinkConfig, error) {
+	config := &PakaiLinkConfig{
+		CallbackTokenHeader: Getenv("PAKAILINK_CALLBACK_TOKEN_HEADER"),
+		AllowedIPs:          getEnvList("PAKAILINK_ALLOWED_IPS"),
+	}
+	if config.CallbackTokenHeader == "" {
+		config.CallbackTokenHeader = "X-CALLBACK-TOKEN"
+	}
+	var errs []error
+
+	for _, slot := range []struct{ name, prefix string }{
+		{CredentialCurrent, "PAKAILINK_"},
+		{CredentialNext, "PAKAILINK_NEXT_"},
+	} {
+		credential := PakaiLinkCredential{
+			Name:             slot.name,
+			ClientSecret:     Getenv(slot.prefix + "CLIENT_SECRET"),
+			RSAPublicKeyPath: Getenv(slot.prefix + "RSA_PUBLIC_KEY_PATH"),
+			CallbackToken:    Getenv(slot.prefix + "CALLBACK_TOKEN"),
+		}
+
+		expiresAt, err := getEnvTime(slot.prefix + "EXPIRES_AT")
+		if err != nil {
+			errs = append(errs, err)
 		}
+		credential.ExpiresAt = expiresAt
 
 		// Load RSA public key if path is provided
-		if config.RSAPublicKeyPath != "" {
-			publicKey, err := loadRSAPublicKey(config.RSAPublicKeyPath)
-			if err == nil {
-				config.RSAPublicKey = publicKey
+		if credential.RSAPublicKeyPath != "" {
+			publicKey, err := loadRSAPublicKey(credential.RSAPublicKeyPath)
+			if err != nil {
+				errs = append(errs, err)
+			} else {
+				credential.RSAPublicKey = publicKey
 			}
 		}
 
-		pakaiLinkConfig = config
+		if credential.ClientSecret != "" || credential.RSAPublicKey != nil || credential.CallbackToken != "" {
+			config.Credentials = append(config.Credentials, credential)
+		}
 	}
-	return pakaiLinkConfig
+
+	return config, errors.Join(errs...)
 }
 
-// loadRSAPublicKey loads RSA public key from PEM file
+// loadRSAPublicKey loads an RSA public key from a PEM file
+// Accepts "PUBLIC KEY" (PKIX), "RSA PUBLIC KEY" (PKCS#1) and "CERTIFICATE" blocks
 func loadRSAPublicKey(path string) (*rsa.PublicKey, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("read RSA public key %s: %w", path, err)
 	}
 
 	block, _ := pem.Decode(data)
 	if block == nil {
-		return nil, err
+		return nil, fmt.Errorf("RSA public key %s: no PEM block found", path)
 	}
 
-	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	var pub interface{}
+	switch block.Type {
+	case "PUBLIC KEY":
+		pub, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		pub, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	case "CERTIFICATE":
+		var cert *x509.Certificate
+		cert, err = x509.ParseCertificate(block.Bytes)
+		if err == nil {
+			pub = cert.PublicKey
+		}
+	case "PRIVATE KEY", "RSA PRIVATE KEY", "ENCRYPTED PRIVATE KEY":
+		return nil, fmt.Errorf("RSA public key %s: file contains a private key (%s), expected a public key", path, block.Type)
+	default:
+		return nil, fmt.Errorf("RSA public key %s: unsupported PEM type %q", path, block.Type)
+	}
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("RSA public key %s: parse %s: %w", path, block.Type, err)
 	}
 
 	rsaPub, ok := pub.(*rsa.PublicKey)
 	if !ok {
-		return nil, err
+		return nil, fmt.Errorf("RSA public key %s: key type %T is not RSA", path, pub)
 	}
 
 	return rsaPub, nil
 }
-
@@ -0,0 +1,22 @@
+package config
+
+import "time"
+
+// ReplayConfig controls replay protection for signed inbound callbacks
+type ReplayConfig struct {
+	MaxSkew  time.Duration
+	NonceTTL time.Duration
+}
+
+func GetReplayConfig() *ReplayConfig {
+	cfg := &ReplayConfig{
+		MaxSkew:  getEnvDuration("WEBHOOK_REPLAY_MAX_SKEW", 5*time.Minute),
+		NonceTTL: getEnvDuration("WEBHOOK_REPLAY_NONCE_TTL", 24*time.Hour),
+	}
+
+	// A nonce must outlive every timestamp that still passes the skew check
+	if cfg.NonceTTL < 2*cfg.MaxSkew {
+		cfg.NonceTTL = 2 * cfg.MaxSkew
+	}
+	return cfg
+}
@@ -0,0 +1,131 @@
+package config
+
+import (
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// SnapCredential is one accepted set of SNAP BI verification material for a partner
+type SnapCredential struct {
+	Name             string
+	ClientSecret     string
+	RSAPublicKey     *rsa.PublicKey
+	RSAPublicKeyPath string
+	ExpiresAt        *time.Time
+}
+
+// SnapPartnerConfig configures one inbound SNAP BI partner (bank or aggregator, e.g. DOKU)
+type SnapPartnerConfig struct {
+	// Code names the partner in routes (/snap/<code>), alerts and webhook_events.provider
+	Code string
+	// PartnerID is the expected X-PARTNER-ID
+	PartnerID string
+	// ChannelIDs restricts CHANNEL-ID; empty accepts any channel
+	ChannelIDs []string
+	// Credentials holds the current set and, during a rotation, the next one
+	Credentials []SnapCredential
+	AllowedIPs  []string
+	// VAExpiry is how long a virtual account can be paid after it was created
+	VAExpiry time.Duration
+}
+
+var snapPartners map[string]*SnapPartnerConfig
+
+// GetSnapPartners returns every partner listed in SNAP_PARTNERS, keyed by code
+func GetSnapPartners() map[string]*SnapPartnerConfig {
+	providerMu.RLock()
+	partners := snapPartners
+	providerMu.RUnlock()
+	if partners != nil {
+		return partners
+	}
+
+	providerMu.Lock()
+	defer providerMu.Unlock()
+	if snapPartners == nil {
+		partners, err := loadSnapPartners()
+		if err != nil {
+			log.Printf("SNAP config: %v", err)
+		}
+		snapPartners = partners
+	}
+	return snapPartners
+}
+
+// GetSnapPartner returns the partner with the given code, or nil when it is not configured
+func GetSnapPartner(code string) *SnapPartnerConfig {
+	return GetSnapPartners()[code]
+}
+
+// ActiveCredentials returns the configured, unexpired credentials
+func (c *SnapPartnerConfig) ActiveCredentials(now time.Time) []SnapCredential {
+	var active []SnapCredential
+	for _, credential := range c.Credentials {
+		if credentialActive(credential.ExpiresAt, now) {
+			active = append(active, credential)
+		}
+	}
+	return active
+}
+
+// snapEnvPrefix returns the env prefix of a partner, e.g. SNAP_DOKU_
+func snapEnvPrefix(code string) string {
+	return "SNAP_" + strings.ToUpper(code) + "_"
+}
+
+// loadSnapPartners reads SNAP_PARTNERS and, for every partner, SNAP_<CODE>_* (current)
+// and SNAP_<CODE>_NEXT_* (next) credentials from env
+func loadSnapPartners() (map[string]*SnapPartnerConfig, error) {
+	partners := make(map[string]*SnapPartnerConfig)
+	var errs []error
+
+	for _, code := range getEnvList("SNAP_PARTNERS") {
+		code = strings.ToUpper(code)
+		prefix := snapEnvPrefix(code)
+		partner := &SnapPartnerConfig{
+			Code:       code,
+			PartnerID:  Getenv(prefix + "PARTNER_ID"),
+			ChannelIDs: getEnvList(prefix + "CHANNEL_IDS"),
+			AllowedIPs: getEnvList(prefix + "ALLOWED_IPS"),
+			VAExpiry:   getEnvDuration(prefix+"VA_EXPIRY", 24*time.Hour),
+		}
+
+		for _, slot := range []struct{ name, prefix string }{
+			{CredentialCurrent, prefix},
+			{CredentialNext, prefix + "NEXT_"},
+		} {
+			credential := SnapCredential{
+				Name:             slot.name,
+				ClientSecret:     Getenv(slot.prefix + "CLIENT_SECRET"),
+				RSAPublicKeyPath: Getenv(slot.prefix + "RSA_PUBLIC_KEY_PATH"),
+			}
+
+			expiresAt, err := getEnvTime(slot.prefix + "EXPIRES_AT")
+			if err != nil {
+				errs = append(errs, err)
+			}
+			credential.ExpiresAt = expiresAt
+
+			if credential.RSAPublicKeyPath != "" {
+				publicKey, err := loadRSAPublicKey(credential.RSAPublicKeyPath)
+				if err != nil {
+					errs = append(errs, fmt.Errorf("%s: %w", code, err))
+				} else {
+					credential.RSAPublicKey = publicKey
+				}
+			}
+
+			if credential.ClientSecret != "" || credential.RSAPublicKey != nil {
+				partner.Credentials = append(partner.Credentials, credential)
+			}
+		}
+
+		partners[code] = partner
+	}
+
+	return partners, errors.Join(errs...)
+}
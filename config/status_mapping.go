@@ -0,0 +1,187 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+// Outcomes a provider status can map to
+const (
+	StatusOutcomeSuccess = "success"
+	StatusOutcomePending = "pending"
+	StatusOutcomeFailed  = "failed"
+	// StatusOutcomeCaptured is a success whose funds are settled by a later settlement callback (card capture);
+	// the payment waits in Pending_Settlement and the wallet is credited by the settlement
+	StatusOutcomeCaptured = "captured"
+	// StatusOutcomeRefunded reverses a successful payment
+	StatusOutcomeRefunded = "refunded"
+	// StatusOutcomeReview holds the event for manual review instead of changing any balance
+	StatusOutcomeReview = "review"
+)
+
+// StatusMappingAny matches every provider or channel in the status mapping
+const StatusMappingAny = "*"
+
+// StatusMapping maps provider → channel → provider status → outcome
+// Provider, channel and status keys are stored in upper case; channel "*" applies to every channel
+// of a provider and provider "*" to every provider. A status that is not mapped is held for review
+type StatusMapping map[string]map[string]map[string]string
+
+// defaultStatusMapping lists the statuses each adapter is known to send
+var defaultStatusMapping = StatusMapping{
+	"LINKQU": {
+		StatusMappingAny: {
+			"SUCCESS": StatusOutcomeSuccess, "SUCCEEDED": StatusOutcomeSuccess, "PAID": StatusOutcomeSuccess,
+			"COMPLETED": StatusOutcomeSuccess, "00": StatusOutcomeSuccess,
+			"PENDING": StatusOutcomePending, "IN_PROGRESS": StatusOutcomePending, "OPEN": StatusOutcomePending,
+			"FAILED": StatusOutcomeFailed, "EXPIRED": StatusOutcomeFailed, "CANCELLED": StatusOutcomeFailed,
+		},
+	},
+	// Raw SNAP paymentFlagStatus codes; a rejected or timed out VA payment leaves the VA open
+	"PAKAILINK": {
+		"VA": {
+			"00": StatusOutcomeSuccess,
+			"01": StatusOutcomePending, "02": StatusOutcomePending,
+		},
+		"PAYOUT_BANK": {
+			"00": StatusOutcomeSuccess,
+			"01": StatusOutcomePending, "02": StatusOutcomePending, "03": StatusOutcomePending,
+			"05": StatusOutcomeFailed, "06": StatusOutcomeFailed,
+		},
+		"PAYOUT_EWALLET": {
+			"00": StatusOutcomeSuccess,
+			"01": StatusOutcomePending, "02": StatusOutcomePending, "03": StatusOutcomePending,
+			"05": StatusOutcomeFailed, "06": StatusOutcomeFailed,
+		},
+	},
+	"XENDIT": {
+		StatusMappingAny: {
+			"PAID": StatusOutcomeSuccess, "SUCCEEDED": StatusOutcomeSuccess, "COMPLETED": StatusOutcomeSuccess,
+			"PENDING": StatusOutcomePending,
+			"EXPIRED": StatusOutcomeFailed, "FAILED": StatusOutcomeFailed,
+		},
+	},
+	"MIDTRANS": {
+		StatusMappingAny: {
+			"CAPTURED": StatusOutcomeCaptured, "SUCCESS": StatusOutcomeSuccess,
+			"PENDING": StatusOutcomePending,
+			"FAILED":  StatusOutcomeFailed, "EXPIRED": StatusOutcomeFailed,
+			"REFUND": StatusOutcomeRefunded,
+		},
+	},
+	// Canonical statuses emitted by adapters that translate the provider status themselves (e.g. SNAP partners)
+	StatusMappingAny: {
+		StatusMappingAny: {
+			"SUCCESS": StatusOutcomeSuccess,
+			"PENDING": StatusOutcomePending,
+			"FAILED":  StatusOutcomeFailed,
+		},
+	},
+}
+
+var statusMapping StatusMapping
+
+// GetStatusMapping returns the default mapping merged with STATUS_MAPPING_FILE
+func GetStatusMapping() StatusMapping {
+	providerMu.RLock()
+	mapping := statusMapping
+	providerMu.RUnlock()
+	if mapping != nil {
+		return mapping
+	}
+
+	providerMu.Lock()
+	defer providerMu.Unlock()
+	if statusMapping == nil {
+		mapping, err := loadStatusMapping()
+		if err != nil {
+			log.Printf("Status mapping: %v", err)
+		}
+		statusMapping = mapping
+	}
+	return statusMapping
+}
+
+// Resolve returns the outcome of a provider status, trying provider + channel, provider + "*" and "*" + "*"
+// The second result is false when the status is not mapped
+func (m StatusMapping) Resolve(provider, channel, status string) (string, bool) {
+	provider = strings.ToUpper(provider)
+	channel = strings.ToUpper(channel)
+	status = strings.ToUpper(strings.TrimSpace(status))
+
+	for _, key := range []struct{ provider, channel string }{
+		{provider, channel},
+		{provider, StatusMappingAny},
+		{StatusMappingAny, StatusMappingAny},
+	} {
+		if outcome, ok := m[key.provider][key.channel][status]; ok {
+			return outcome, true
+		}
+	}
+	return "", false
+}
+
+// GetStatusMappingFile is the optional JSON file with status mapping overrides
+func GetStatusMappingFile() string {
+	return Getenv("STATUS_MAPPING_FILE")
+}
+
+// loadStatusMapping copies the defaults and applies the entries of STATUS_MAPPING_FILE on top
+// The file has the same shape as StatusMapping, e.g. {"LinkQu": {"PAYOUT_BANK": {"REVERSED": "failed"}}}
+// On error the defaults are returned together with the error
+func loadStatusMapping() (StatusMapping, error) {
+	mapping := StatusMapping{}
+	mapping.merge(defaultStatusMapping)
+
+	path := GetStatusMappingFile()
+	if path == "" {
+		return mapping, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return mapping, fmt.Errorf("read status mapping %s: %w", path, err)
+	}
+
+	var overrides StatusMapping
+	if err := json.Unmarshal(data, &overrides); err != nil {
+		return mapping, fmt.Errorf("status mapping %s: %w", path, err)
+	}
+
+	for provider, channels := range overrides {
+		for channel, statuses := range channels {
+			for status, outcome := range statuses {
+				switch strings.ToLower(outcome) {
+				case StatusOutcomeSuccess, StatusOutcomeCaptured, StatusOutcomePending, StatusOutcomeFailed, StatusOutcomeRefunded, StatusOutcomeReview:
+				default:
+					return mapping, fmt.Errorf("status mapping %s: %s %s %s: unknown outcome %q", path, provider, channel, status, outcome)
+				}
+			}
+		}
+	}
+
+	mapping.merge(overrides)
+	return mapping, nil
+}
+
+// merge copies the entries of other into m with upper case keys and lower case outcomes
+func (m StatusMapping) merge(other StatusMapping) {
+	for provider, channels := range other {
+		provider = strings.ToUpper(provider)
+		if m[provider] == nil {
+			m[provider] = map[string]map[string]string{}
+		}
+		for channel, statuses := range channels {
+			channel = strings.ToUpper(channel)
+			if m[provider][channel] == nil {
+				m[provider][channel] = map[string]string{}
+			}
+			for status, outcome := range statuses {
+				m[provider][channel][strings.ToUpper(status)] = strings.ToLower(outcome)
+			}
+		}
+	}
+}
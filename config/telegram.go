@@ -1,7 +1,5 @@
 package config
 
-import "os"
-
 type TelegramConfig struct {
 	Token  string
 	ChatID string
@@ -9,8 +7,8 @@ type TelegramConfig struct {
 
 func GetTelegramConfig() *TelegramConfig {
 	return &TelegramConfig{
-		Token:  os.Getenv("TELEGRAM_TOKEN"),
-		ChatID: os.Getenv("TELEGRAM_CHAT_ID"),
+		Token:  Getenv("TELEGRAM_TOKEN"),
+		ChatID: Getenv("TELEGRAM_CHAT_ID"),
 	}
 }
 
@@ -0,0 +1,308 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Config issue severities; errors stop the service from starting
+const (
+	SeverityError   = "ERROR"
+	SeverityWarning = "WARNING"
+)
+
+// ConfigIssue is one finding of ValidateConfig
+type ConfigIssue struct {
+	Severity string
+	Provider string
+	Field    string
+	Message  string
+}
+
+// ConfigReport lists every issue found in the configuration
+type ConfigReport struct {
+	Issues []ConfigIssue
+}
+
+// secretHashPattern matches helpers.HashSecret output
+var secretHashPattern = regexp.MustCompile(`^sha256:[0-9a-fA-F]+:[0-9a-fA-F]{64}$`)
+
+// HasErrors reports whether the report contains at least one error
+func (r *ConfigReport) HasErrors() bool {
+	for _, issue := range r.Issues {
+		if issue.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// String formats the report one issue per line
+func (r *ConfigReport) String() string {
+	if len(r.Issues) == 0 {
+		return "Configuration OK"
+	}
+
+	var b strings.Builder
+	errorCount, warningCount := 0, 0
+	for _, issue := range r.Issues {
+		if issue.Severity == SeverityError {
+			errorCount++
+		} else {
+			warningCount++
+		}
+		fmt.Fprintf(&b, "[%s] %s %s: %s\n", issue.Severity, issue.Provider, issue.Field, issue.Message)
+	}
+	fmt.Fprintf(&b, "%d error(s), %d warning(s)", errorCount, warningCount)
+	return b.String()
+}
+
+func (r *ConfigReport) add(severity, provider, field, format string, args ...interface{}) {
+	r.Issues = append(r.Issues, ConfigIssue{
+		Severity: severity,
+		Provider: provider,
+		Field:    field,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// ValidateConfig checks the provider, network and alerting configuration from the environment
+func ValidateConfig() *ConfigReport {
+	report := &ConfigReport{}
+	now := time.Now()
+
+	validateLinkQu(report, now)
+	validatePakaiLink(report, now)
+	validateXendit(report, now)
+	validateMidtrans(report, now)
+	validateSnapPartners(report, now)
+
+	validateIPList(report, SeverityError, "network", "TRUSTED_PROXIES")
+	validateContainerProxies(report)
+	validateWorker(report)
+
+	if _, err := loadStatusMapping(); err != nil {
+		report.add(SeverityError, "status mapping", "STATUS_MAPPING_FILE", "%v", err)
+	}
+
+	if _, err := loadAmountCheck(); err != nil {
+		report.add(SeverityError, "amount check", "AMOUNT_MISMATCH_*", "%v", err)
+	}
+
+	for _, key := range ShadowedEnvKeys() {
+		report.add(SeverityWarning, "env", key, "also set in the process environment, which wins; changes in .env are not applied on reload")
+	}
+
+	if Getenv("TELEGRAM_TOKEN") == "" || Getenv("TELEGRAM_CHAT_ID") == "" {
+		report.add(SeverityWarning, "telegram", "TELEGRAM_TOKEN / TELEGRAM_CHAT_ID", "not set, alerts will not be delivered")
+	}
+
+	return report
+}
+
+func validateLinkQu(report *ConfigReport, now time.Time) {
+	active := 0
+	for _, slot := range []struct{ name, prefix string }{
+		{CredentialCurrent, "LINKQU_"},
+		{CredentialNext, "LINKQU_NEXT_"},
+	} {
+		clientID := Getenv(slot.prefix + "CLIENT_ID")
+		secret := Getenv(slot.prefix + "CLIENT_SECRET")
+		secretHash := Getenv(slot.prefix + "CLIENT_SECRET_HASH")
+		if slot.name == CredentialNext && clientID == "" {
+			clientID = Getenv("LINKQU_CLIENT_ID")
+		}
+
+		if secretHash != "" && !secretHashPattern.MatchString(strings.TrimSpace(secretHash)) {
+			report.add(SeverityError, "linkqu", slot.prefix+"CLIENT_SECRET_HASH", "not a salted hash, generate one with -hash-secret")
+		}
+		if secret != "" && secretHash != "" {
+			report.add(SeverityWarning, "linkqu", slot.prefix+"CLIENT_SECRET", "ignored because %sCLIENT_SECRET_HASH is set", slot.prefix)
+		}
+		if (secret != "" || secretHash != "") && clientID == "" {
+			report.add(SeverityError, "linkqu", slot.prefix+"CLIENT_ID", "secret is set without a client id")
+		}
+
+		expiresAt, ok := validateExpiry(report, "linkqu", slot.prefix+"EXPIRES_AT", now)
+		if clientID != "" && (secret != "" || secretHash != "") && ok && credentialActive(expiresAt, now) {
+			active++
+		}
+	}
+
+	if active == 0 {
+		report.add(SeverityWarning, "linkqu", "credentials", "no active credential, every LinkQu callback will be rejected")
+	}
+	validateIPList(report, SeverityError, "linkqu", "LINKQU_ALLOWED_IPS")
+}
+
+func validatePakaiLink(report *ConfigReport, now time.Time) {
+	active, tokens := 0, 0
+	for _, slot := range []struct{ name, prefix string }{
+		{CredentialCurrent, "PAKAILINK_"},
+		{CredentialNext, "PAKAILINK_NEXT_"},
+	} {
+		token := Getenv(slot.prefix+"CALLBACK_TOKEN") != ""
+		configured := Getenv(slot.prefix+"CLIENT_SECRET") != "" || token
+
+		if path := Getenv(slot.prefix + "RSA_PUBLIC_KEY_PATH"); path != "" {
+			if _, err := loadRSAPublicKey(path); err != nil {
+				report.add(SeverityError, "pakailink", slot.prefix+"RSA_PUBLIC_KEY_PATH", "%v", err)
+			} else {
+				configured = true
+			}
+		}
+
+		expiresAt, ok := validateExpiry(report, "pakailink", slot.prefix+"EXPIRES_AT", now)
+		if configured && ok && credentialActive(expiresAt, now) {
+			active++
+			if token {
+				tokens++
+			}
+		}
+	}
+
+	allowlist := validateIPList(report, SeverityError, "pakailink", "PAKAILINK_ALLOWED_IPS")
+	if active == 0 && allowlist == 0 {
+		report.add(SeverityWarning, "pakailink", "credentials", "no active secret, RSA key, callback token or IP allowlist, every PakaiLink callback will be rejected")
+	} else if tokens == 0 && allowlist == 0 {
+		// Secrets and RSA keys only verify X-SIGNATURE; unsigned callbacks (e.g. payouts) need a token or the allowlist
+		report.add(SeverityWarning, "pakailink", "PAKAILINK_CALLBACK_TOKEN / PAKAILINK_ALLOWED_IPS", "neither is set, every PakaiLink callback without X-SIGNATURE (e.g. payouts) will be rejected")
+	}
+}
+
+func validateXendit(report *ConfigReport, now time.Time) {
+	active := 0
+	for _, prefix := range []string{"XENDIT_", "XENDIT_NEXT_"} {
+		expiresAt, ok := validateExpiry(report, "xendit", prefix+"EXPIRES_AT", now)
+		if Getenv(prefix+"CALLBACK_TOKEN") != "" && ok && credentialActive(expiresAt, now) {
+			active++
+		}
+	}
+
+	if active == 0 {
+		report.add(SeverityWarning, "xendit", "credentials", "no active callback token, every Xendit callback will be rejected")
+	}
+	validateIPList(report, SeverityError, "xendit", "XENDIT_ALLOWED_IPS")
+}
+
+func validateMidtrans(report *ConfigReport, now time.Time) {
+	active := 0
+	for _, prefix := range []string{"MIDTRANS_", "MIDTRANS_NEXT_"} {
+		expiresAt, ok := validateExpiry(report, "midtrans", prefix+"EXPIRES_AT", now)
+		if Getenv(prefix+"SERVER_KEY") != "" && ok && credentialActive(expiresAt, now) {
+			active++
+		}
+	}
+
+	if active == 0 {
+		report.add(SeverityWarning, "midtrans", "credentials", "no active server key, every Midtrans notification will be rejected")
+	}
+	validateIPList(report, SeverityError, "midtrans", "MIDTRANS_ALLOWED_IPS")
+}
+
+func validateSnapPartners(report *ConfigReport, now time.Time) {
+	for _, code := range getEnvList("SNAP_PARTNERS") {
+		provider := "snap " + strings.ToLower(code)
+		prefix := snapEnvPrefix(code)
+
+		if Getenv(prefix+"PARTNER_ID") == "" {
+			report.add(SeverityError, provider, prefix+"PARTNER_ID", "not set, X-PARTNER-ID cannot be checked")
+		}
+
+		active := 0
+		for _, slotPrefix := range []string{prefix, prefix + "NEXT_"} {
+			configured := Getenv(slotPrefix+"CLIENT_SECRET") != ""
+
+			if path := Getenv(slotPrefix + "RSA_PUBLIC_KEY_PATH"); path != "" {
+				if _, err := loadRSAPublicKey(path); err != nil {
+					report.add(SeverityError, provider, slotPrefix+"RSA_PUBLIC_KEY_PATH", "%v", err)
+				} else {
+					configured = true
+				}
+			}
+
+			expiresAt, ok := validateExpiry(report, provider, slotPrefix+"EXPIRES_AT", now)
+			if configured && ok && credentialActive(expiresAt, now) {
+				active++
+			}
+		}
+
+		if active == 0 {
+			report.add(SeverityWarning, provider, "credentials", "no active client secret or RSA key, every request will be rejected")
+		}
+		validateIPList(report, SeverityError, provider, prefix+"ALLOWED_IPS")
+	}
+}
+
+// validateContainerProxies rejects loopback-only TRUSTED_PROXIES inside a container when an IP allowlist is set:
+// a proxy on the host reaches the container through the docker gateway, so the client IP would be the gateway
+// and every allowlisted callback would be rejected
+func validateContainerProxies(report *ConfigReport) {
+	if _, err := os.Stat("/.dockerenv"); err != nil {
+		return
+	}
+
+	allowlists := []string{"LINKQU_ALLOWED_IPS", "PAKAILINK_ALLOWED_IPS", "XENDIT_ALLOWED_IPS", "MIDTRANS_ALLOWED_IPS"}
+	for _, code := range getEnvList("SNAP_PARTNERS") {
+		allowlists = append(allowlists, snapEnvPrefix(code)+"ALLOWED_IPS")
+	}
+	allowlisted := false
+	for _, key := range allowlists {
+		if len(getEnvList(key)) > 0 {
+			allowlisted = true
+			break
+		}
+	}
+	if !allowlisted {
+		return
+	}
+
+	for _, entry := range GetNetworkConfig().TrustedProxies {
+		if ip := net.ParseIP(entry); ip == nil || !ip.IsLoopback() {
+			return
+		}
+	}
+	report.add(SeverityError, "network", "TRUSTED_PROXIES", "only loopback addresses while running in a container with an IP allowlist; set it to the docker network gateway (172.28.0.1 with the shipped docker-compose.yml)")
+}
+
+// validateWorker warns when WEBHOOK_WORKER_LOCK_TIMEOUT is raised to outlive the processing timeout
+func validateWorker(report *ConfigReport) {
+	cfg := GetWorkerConfig()
+	if lockTimeout := getEnvDuration("WEBHOOK_WORKER_LOCK_TIMEOUT", cfg.LockTimeout); lockTimeout < cfg.LockTimeout {
+		report.add(SeverityWarning, "worker", "WEBHOOK_WORKER_LOCK_TIMEOUT", "%s is shorter than WEBHOOK_WORKER_PROCESS_TIMEOUT (%s) + %s, using %s", lockTimeout, cfg.ProcessTimeout, LockTimeoutMargin, cfg.LockTimeout)
+	}
+}
+
+// validateExpiry checks an optional RFC 3339 expiry; ok is false when the value cannot be parsed
+func validateExpiry(report *ConfigReport, provider, key string, now time.Time) (*time.Time, bool) {
+	expiresAt, err := getEnvTime(key)
+	if err != nil {
+		report.add(SeverityError, provider, key, "%v", err)
+		return nil, false
+	}
+	if expiresAt != nil && !credentialActive(expiresAt, now) {
+		report.add(SeverityWarning, provider, key, "credential expired at %s", expiresAt.Format(time.RFC3339))
+	}
+	return expiresAt, true
+}
+
+// validateIPList checks a comma separated list of IPs / CIDR ranges and returns the number of valid entries
+func validateIPList(report *ConfigReport, severity, provider, key string) int {
+	valid := 0
+	for _, entry := range getEnvList(key) {
+		if _, _, err := net.ParseCIDR(entry); err == nil {
+			valid++
+			continue
+		}
+		if net.ParseIP(entry) != nil {
+			valid++
+			continue
+		}
+		report.add(severity, provider, key, "invalid IP or CIDR %q", entry)
+	}
+	return valid
+}
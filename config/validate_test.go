@@ -0,0 +1,73 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidatePakaiLinkUnsignedFallback(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		warn bool
+	}{
+		{"secret only", map[string]string{"PAKAILINK_CLIENT_SECRET": "secret"}, true},
+		{"secret and token", map[string]string{"PAKAILINK_CLIENT_SECRET": "secret", "PAKAILINK_CALLBACK_TOKEN": "token"}, false},
+		{"secret and allowlist", map[string]string{"PAKAILINK_CLIENT_SECRET": "secret", "PAKAILINK_ALLOWED_IPS": "203.0.113.10"}, false},
+		{"next token only", map[string]string{"PAKAILINK_CLIENT_SECRET": "secret", "PAKAILINK_NEXT_CALLBACK_TOKEN": "token"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"PAKAILINK_CLIENT_SECRET", "PAKAILINK_CALLBACK_TOKEN", "PAKAILINK_NEXT_CALLBACK_TOKEN", "PAKAILINK_ALLOWED_IPS"} {
+				t.Setenv(key, tt.env[key])
+			}
+
+			report := &ConfigReport{}
+			validatePakaiLink(report, time.Now())
+
+			warned := false
+			for _, issue := range report.Issues {
+				if strings.Contains(issue.Message, "without X-SIGNATURE") {
+					warned = true
+				}
+			}
+			if warned != tt.warn {
+				t.Errorf("warning = %v, want %v\n%s", warned, tt.warn, report)
+			}
+		})
+	}
+}
+
+func TestWorkerLockTimeoutOutlivesProcessTimeout(t *testing.T) {
+	tests := []struct {
+		name           string
+		processTimeout string
+		lockTimeout    string
+		want           time.Duration
+		warn           bool
+	}{
+		{"defaults", "", "", 5 * time.Minute, false},
+		{"lease long enough", "2m", "3m", 3 * time.Minute, false},
+		{"lease equal to timeout", "2m", "2m", 3 * time.Minute, true},
+		{"lease shorter than default timeout", "", "30s", 2 * time.Minute, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("WEBHOOK_WORKER_PROCESS_TIMEOUT", tt.processTimeout)
+			t.Setenv("WEBHOOK_WORKER_LOCK_TIMEOUT", tt.lockTimeout)
+
+			if got := GetWorkerConfig().LockTimeout; got != tt.want {
+				t.Errorf("LockTimeout = %s, want %s", got, tt.want)
+			}
+
+			report := &ConfigReport{}
+			validateWorker(report)
+			if warned := len(report.Issues) > 0; warned != tt.warn {
+				t.Errorf("warning = %v, want %v\n%s", warned, tt.warn, report)
+			}
+		})
+	}
+}
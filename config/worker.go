@@ -0,0 +1,67 @@
+package config
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+type WorkerConfig struct {
+	Concurrency  int
+	PollInterval time.Duration
+	MaxAttempts  int
+	RetryDelay   time.Duration
+	// ProcessTimeout bounds the database transaction of one event; it is rolled back once the timeout passes
+	ProcessTimeout time.Duration
+	// LockTimeout is how long a claimed event stays leased before it is re-queued, at least ProcessTimeout + LockTimeoutMargin
+	LockTimeout time.Duration
+}
+
+// LockTimeoutMargin is the minimum time a lease outlives the processing timeout, covering clock skew between instances
+const LockTimeoutMargin = time.Minute
+
+func GetWorkerConfig() *WorkerConfig {
+	cfg := &WorkerConfig{
+		Concurrency:    getEnvInt("WEBHOOK_WORKER_CONCURRENCY", 4),
+		PollInterval:   getEnvDuration("WEBHOOK_WORKER_POLL_INTERVAL", 2*time.Second),
+		MaxAttempts:    getEnvInt("WEBHOOK_WORKER_MAX_ATTEMPTS", 5),
+		RetryDelay:     getEnvDuration("WEBHOOK_WORKER_RETRY_DELAY", 30*time.Second),
+		ProcessTimeout: getEnvDuration("WEBHOOK_WORKER_PROCESS_TIMEOUT", time.Minute),
+		LockTimeout:    getEnvDuration("WEBHOOK_WORKER_LOCK_TIMEOUT", 5*time.Minute),
+	}
+
+	// A lease that can expire while the event is still processing lets a second worker claim it
+	if minimum := cfg.ProcessTimeout + LockTimeoutMargin; cfg.LockTimeout < minimum {
+		cfg.LockTimeout = minimum
+	}
+	return cfg
+}
+
+// getEnvInt reads a positive integer from env, falling back to def
+func getEnvInt(key string, def int) int {
+	value, err := strconv.Atoi(Getenv(key))
+	if err != nil || value <= 0 {
+		return def
+	}
+	return value
+}
+
+// getEnvDuration reads a Go duration (e.g. "30s", "5m") from env, falling back to def
+func getEnvDuration(key string, def time.Duration) time.Duration {
+	value, err := time.ParseDuration(Getenv(key))
+	if err != nil || value <= 0 {
+		return def
+	}
+	return value
+}
+
+// getEnvList reads a comma separated env value, dropping empty items
+func getEnvList(key string) []string {
+	var items []string
+	for _, item := range strings.Split(Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
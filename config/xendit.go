@@ -0,0 +1,83 @@
+package config
+
+import (
+	"errors"
+	"log"
+	"time"
+)
+
+// XenditCredential is one accepted x-callback-token (the verification token from the Xendit dashboard)
+type XenditCredential struct {
+	Name          string
+	CallbackToken string
+	ExpiresAt     *time.Time
+}
+
+type XenditConfig struct {
+	// Credentials holds the current token and, during a rotation, the next one
+	Credentials []XenditCredential
+	AllowedIPs  []string
+}
+
+var xenditConfig *XenditConfig
+
+func GetXenditConfig() *XenditConfig {
+	providerMu.RLock()
+	config := xenditConfig
+	providerMu.RUnlock()
+	if config != nil {
+		return config
+	}
+
+	providerMu.Lock()
+	defer providerMu.Unlock()
+	if xenditConfig == nil {
+		config, err := loadXenditConfig()
+		if err != nil {
+			log.Printf("Xendit config: %v", err)
+		}
+		xenditConfig = config
+	}
+	return xenditConfig
+}
+
+// ActiveCredentials returns the configured, unexpired credentials
+func (c *XenditConfig) ActiveCredentials(now time.Time) []XenditCredential {
+	var active []XenditCredential
+	for _, credential := range c.Credentials {
+		if credentialActive(credential.ExpiresAt, now) {
+			active = append(active, credential)
+		}
+	}
+	return active
+}
+
+// loadXenditConfig reads XENDIT_* (current) and XENDIT_NEXT_* (next) tokens from env
+func loadXenditConfig() (*XenditConfig, error) {
+	config := &XenditConfig{
+		AllowedIPs: getEnvList("XENDIT_ALLOWED_IPS"),
+	}
+	var errs []error
+
+	for _, slot := range []struct{ name, prefix string }{
+		{CredentialCurrent, "XENDIT_"},
+		{CredentialNext, "XENDIT_NEXT_"},
+	} {
+		credential := XenditCredential{
+			Name:          slot.name,
+			CallbackToken: Getenv(slot.prefix + "CALLBACK_TOKEN"),
+		}
+
+		expiresAt, err := getEnvTime(slot.prefix + "EXPIRES_AT")
+		if err != nil {
+			errs = append(errs, err)
+		}
+		credential.ExpiresAt = expiresAt
+
+		if credential.CallbackToken != "" {
+			config.Credentials = append(config.Credentials, credential)
+		}
+	}
+
+	return config, errors.Join(errs...)
+}
@@ -0,0 +1,169 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kytapay/webhook-v2/config"
+	"github.com/kytapay/webhook-v2/repositories"
+	"github.com/kytapay/webhook-v2/services"
+)
+
+type AdminController struct {
+	transactionRepo  *repositories.TransactionRepository
+	merchantRepo     *repositories.MerchantRepository
+	webhookEventRepo *repositories.WebhookEventRepository
+	callbackDelivery *services.CallbackDeliveryService
+}
+
+func NewAdminController(db *sql.DB, callbackDelivery *services.CallbackDeliveryService) *AdminController {
+	return &AdminController{
+		transactionRepo:  repositories.NewTransactionRepository(db),
+		merchantRepo:     repositories.NewMerchantRepository(db),
+		webhookEventRepo: repositories.NewWebhookEventRepository(db),
+		callbackDelivery: callbackDelivery,
+	}
+}
+
+// ResendCallback rebuilds the merchant callback payload from current DB state and delivers it again
+func (ac *AdminController) ResendCallback(c *gin.Context) {
+	transactionInfoID, err := strconv.Atoi(c.Param("transaction_info_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid transaction_info_id",
+		})
+		return
+	}
+
+	transaction, err := ac.transactionRepo.GetTransactionByID(transactionInfoID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": "Transaction not found",
+		})
+		return
+	}
+
+	loc, err := time.LoadLocation("Asia/Jakarta")
+	if err != nil {
+		loc = time.UTC
+	}
+	date := time.Now().In(loc).Format("2006-01-02T15:04:05Z07:00")
+
+	// Payout callbacks use the payout payload, everything else the payment payload
+	var payload interface{}
+	if payout, err := ac.merchantRepo.GetMerchantPayoutByGatewayRef(transaction.GrantID); err == nil {
+		payload = services.BuildPayloadV2Payout(transaction, transaction.GrantID, payout.Status, date)["PAYOUTS"]
+	} else {
+		payment, err := ac.merchantRepo.GetMerchantPaymentByGatewayRef(transaction.GrantID)
+		if err != nil || payment.MerchantID == nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"success": false,
+				"message": "Merchant payment not found",
+			})
+			return
+		}
+
+		merchant, err := ac.merchantRepo.GetMerchantByID(*payment.MerchantID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"success": false,
+				"message": "Merchant not found",
+			})
+			return
+		}
+
+		payload = services.BuildPayloadV2(transaction, transaction.GrantID, merchant.BusinessName, payment.Status, date)[transaction.PaymentMethod]
+	}
+
+	if payload == nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"success": false,
+			"message": "Unsupported payment method " + transaction.PaymentMethod,
+		})
+		return
+	}
+
+	callback, err := ac.callbackDelivery.Resend(transaction, payload)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": "Callback status not found",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Callback resent",
+		"data":    callback,
+	})
+}
+
+// RequeueWebhookEvent sends a held or failed webhook event through the settlement pipeline again,
+// e.g. after an unmapped provider status was added to the status mapping
+// An optional JSON body {"amount_policy": "flag" | "partial"} accepts a held amount mismatch for this event only
+func (ac *AdminController) RequeueWebhookEvent(c *gin.Context) {
+	eventID, err := strconv.ParseInt(c.Param("webhook_event_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid webhook_event_id",
+		})
+		return
+	}
+
+	var request struct {
+		AmountPolicy string `json:"amount_policy"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid request body",
+		})
+		return
+	}
+	switch request.AmountPolicy {
+	case "", config.AmountPolicyFlag, config.AmountPolicyPartial:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "amount_policy must be flag or partial",
+		})
+		return
+	}
+
+	requeued, err := ac.webhookEventRepo.RequeueEvent(eventID, request.AmountPolicy)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if !requeued {
+		c.JSON(http.StatusConflict, gin.H{
+			"success": false,
+			"message": "Webhook event not found or not held / failed",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Webhook event requeued",
+	})
+}
@@ -0,0 +1,140 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kytapay/webhook-v2/helpers"
+	"github.com/kytapay/webhook-v2/models"
+	"github.com/kytapay/webhook-v2/providers"
+)
+
+// HandleProvider returns the webhook handler for one provider channel:
+// store the raw callback, verify, parse into a canonical event, check for replays, queue and ack
+func (wc *WebhookController) HandleProvider(p providers.Provider) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		source := providers.Source(p)
+
+		// Store the raw callback before anything else
+		event, body, err := wc.receiveEvent(c, p.Name(), p.Channel().Code)
+		if err != nil {
+			respond(c, p, providers.AckRetryLater, "")
+			return
+		}
+
+		req := &providers.Request{
+			Method:   c.Request.Method,
+			Path:     c.Request.URL.Path,
+			Header:   c.Request.Header,
+			Body:     body,
+			ClientIP: c.ClientIP(),
+		}
+
+		// Source IP allowlist, read per request so a credential reload applies immediately
+		if !helpers.IPAllowed(req.ClientIP, p.AllowedIPs()) {
+			wc.markEventVerification(event, models.WebhookVerificationFailed)
+			wc.finishEvent(event, "", models.WebhookStatusRejected, "source IP "+req.ClientIP+" is not allowlisted")
+			if helpers.ShouldAlertRejectedIP(p.Name(), req.ClientIP) {
+				wc.sendTelegramAlert(fmt.Sprintf("🚨 <b>Callback From Unexpected IP</b>\n\n⚠️ <b>Security Alert:</b>\n• Source: %s\n• Route: <code>%s</code>\n• IP Address: <code>%s</code>\n• Action: Rejected (403)", source, req.Path, req.ClientIP), "HTML")
+			}
+			respondError(c, p, providers.AckForbidden, errors.New("source IP not allowed"))
+			return
+		}
+
+		verification, err := p.Verify(req)
+		if err != nil {
+			wc.markEventVerification(event, models.WebhookVerificationFailed)
+			wc.finishEvent(event, "", models.WebhookStatusRejected, err.Error())
+			wc.sendTelegramAlert(fmt.Sprintf("🚨 <b>Unauthorized Callback Attempt</b>\n\n⚠️ <b>Security Alert:</b>\n• Source: %s\n• IP Address: <code>%s</code>\n• Reason: <code>%s</code>", source, req.ClientIP, err.Error()), "HTML")
+			respondError(c, p, providers.AckUnauthorized, err)
+			return
+		}
+		wc.markEventVerification(event, models.WebhookVerificationVerified)
+
+		if err := wc.checkExternalID(event, verification.ExternalID); err != nil {
+			if errors.Is(err, helpers.ErrDuplicateExternalID) {
+				wc.finishEvent(event, "", models.WebhookStatusRejected, err.Error())
+				wc.sendTelegramAlert(fmt.Sprintf("🚨 <b>Duplicate External ID</b>\n\n⚠️ <b>Security Alert:</b>\n• Source: %s\n• IP Address: <code>%s</code>\n• External ID: <code>%s</code>", source, req.ClientIP, verification.ExternalID.ID), "HTML")
+				respondError(c, p, providers.AckReplayed, err)
+				return
+			}
+
+			wc.finishEvent(event, "", models.WebhookStatusFailed, "external id check failed: "+err.Error())
+			wc.sendTelegramAlert(fmt.Sprintf("❌ <b>Error Checking External ID</b>\n\n• Source: %s\n• External ID: <code>%s</code>\n• Error: <code>%s</code>", source, verification.ExternalID.ID, err.Error()), "HTML")
+			respondError(c, p, providers.AckRetryLater, err)
+			return
+		}
+
+		parsed, err := p.Parse(req)
+		if err != nil {
+			wc.finishEvent(event, "", models.WebhookStatusRejected, err.Error())
+			if errors.Is(err, providers.ErrMissingReference) {
+				wc.sendTelegramAlert(fmt.Sprintf("⚠️ <b>Callback Error</b>\n\n• Source: %s\n• Issue: Missing payment ID", source), "HTML")
+			}
+			if errors.Is(err, providers.ErrInvalidAmount) {
+				wc.sendTelegramAlert(fmt.Sprintf("⚠️ <b>Callback Error</b>\n\n• Source: %s\n• Webhook Event ID: %d\n• Issue: <code>%s</code>", source, event.ID, err.Error()), "HTML")
+			}
+			wc.releaseExternalID(event, verification.ExternalID)
+			respondError(c, p, providers.AckInvalid, err)
+			return
+		}
+		partnerRef := parsed.PartnerReference()
+
+		if err := wc.checkReplay(event, partnerRef, verification.ReplayKey); err != nil {
+			if errors.Is(err, helpers.ErrReplayDetected) {
+				wc.finishEvent(event, partnerRef, models.WebhookStatusRejected, err.Error())
+				wc.sendTelegramAlert(fmt.Sprintf("🚨 <b>Callback Replay Detected</b>\n\n⚠️ <b>Security Alert:</b>\n• Source: %s\n• IP Address: <code>%s</code>\n• Payment ID: <code>%s</code>", source, req.ClientIP, partnerRef), "HTML")
+				respondError(c, p, providers.AckReplayed, err)
+				return
+			}
+
+			// Replay cache unavailable, let the provider deliver again
+			wc.finishEvent(event, partnerRef, models.WebhookStatusFailed, "replay check failed: "+err.Error())
+			wc.sendTelegramAlert(fmt.Sprintf("❌ <b>Error Checking Callback Replay</b>\n\n• Source: %s\n• Payment ID: <code>%s</code>\n• Error: <code>%s</code>", source, partnerRef, err.Error()), "HTML")
+			wc.releaseExternalID(event, verification.ExternalID)
+			respondError(c, p, providers.AckRetryLater, err)
+			return
+		}
+
+		// Queue for the webhook worker and acknowledge immediately
+		if err := wc.queueParsedEvent(event, parsed); err != nil {
+			wc.releaseReplay(event, partnerRef, verification.ReplayKey)
+			wc.releaseExternalID(event, verification.ExternalID)
+			respondError(c, p, providers.AckRetryLater, err)
+			return
+		}
+
+		respond(c, p, providers.AckAccepted, "")
+	}
+}
+
+// queueParsedEvent queues a canonical payment, settlement or payout event
+func (wc *WebhookController) queueParsedEvent(event *models.WebhookEvent, parsed *providers.Event) error {
+	if payout := parsed.Payout; payout != nil {
+		return wc.queueEvent(event, models.WebhookEventPayout, payout.PartnerReference, payout.Status, payout.Amount, payout.EventTime, payout.Method)
+	}
+
+	payment := parsed.Payment
+	eventType := models.WebhookEventPayment
+	if payment.Settlement {
+		eventType = models.WebhookEventSettlement
+	}
+	return wc.queueEvent(event, eventType, payment.PartnerReference, payment.Status, payment.Amount, payment.EventTime, payment.Method)
+}
+
+// respond writes the provider's acknowledgement for an outcome
+func respond(c *gin.Context, p providers.Provider, outcome, message string) {
+	status, body := p.Ack(outcome, message)
+	c.JSON(status, body)
+}
+
+// respondError writes the acknowledgement for an outcome caused by err
+func respondError(c *gin.Context, p providers.Provider, outcome string, err error) {
+	if acker, ok := p.(providers.ErrorAcker); ok {
+		status, body := acker.AckError(outcome, err)
+		c.JSON(status, body)
+		return
+	}
+	respond(c, p, outcome, err.Error())
+}
@@ -0,0 +1,199 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kytapay/webhook-v2/config"
+	"github.com/kytapay/webhook-v2/helpers"
+	"github.com/kytapay/webhook-v2/models"
+	"github.com/kytapay/webhook-v2/pkg/snap"
+	"github.com/kytapay/webhook-v2/repositories"
+	"github.com/kytapay/webhook-v2/services"
+)
+
+// SnapController answers synchronous SNAP BI requests from partners (as opposed to notifications,
+// which go through the webhook inbox)
+type SnapController struct {
+	transactionRepo *repositories.TransactionRepository
+	merchantRepo    *repositories.MerchantRepository
+	externalIDRepo  *repositories.SnapExternalIDRepository
+	telegramService *services.TelegramService
+}
+
+func NewSnapController(db *sql.DB) *SnapController {
+	return &SnapController{
+		transactionRepo: repositories.NewTransactionRepository(db),
+		merchantRepo:    repositories.NewMerchantRepository(db),
+		externalIDRepo:  repositories.NewSnapExternalIDRepository(db),
+		telegramService: services.NewTelegramService(),
+	}
+}
+
+// customerNameAlertInterval limits "VA Without Customer Name" alerts to one per partner per interval,
+// the column stays empty for every VA until the app that creates them stores the name
+const customerNameAlertInterval = time.Hour
+
+// lastCustomerNameAlerts holds the time of the last "VA Without Customer Name" alert per partner
+var lastCustomerNameAlerts sync.Map
+
+// snapVAInquiryRequest is the body of POST /v1.0/transfer-va/inquiry
+type snapVAInquiryRequest struct {
+	PartnerServiceID string `json:"partnerServiceId"`
+	CustomerNo       string `json:"customerNo"`
+	VirtualAccountNo string `json:"virtualAccountNo"`
+	InquiryRequestID string `json:"inquiryRequestId"`
+}
+
+// VAInquiry returns the handler for a partner's virtual account inquiry: the bank asks for the bill
+// before the customer pays, the VA is looked up in app_transactions_infos by bank_number
+func (sc *SnapController) VAInquiry(partnerCode string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Source IP allowlist (SNAP_<PARTNER>_ALLOWED_IPS), read per request so a credential reload applies immediately
+		if partner := config.GetSnapPartner(partnerCode); partner != nil && !helpers.IPAllowed(c.ClientIP(), partner.AllowedIPs) {
+			if helpers.ShouldAlertRejectedIP(partnerCode, c.ClientIP()) {
+				sc.sendAlert(fmt.Sprintf("🚨 <b>Callback From Unexpected IP</b>\n\n⚠️ <b>Security Alert:</b>\n• Source: VA Inquiry %s\n• Route: <code>%s</code>\n• IP Address: <code>%s</code>\n• Action: Rejected (403)", partnerCode, c.Request.URL.Path, c.ClientIP()))
+			}
+			sc.respondError(c, snap.Forbidden("Source IP not allowed"))
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			sc.respondError(c, snap.BadRequest())
+			return
+		}
+
+		headers, err := helpers.VerifySnapRequest(partnerCode, c.Request.Method, c.Request.URL.Path, body, c.Request.Header)
+		if err != nil {
+			sc.sendAlert(fmt.Sprintf("🚨 <b>Unauthorized SNAP Request</b>\n\n⚠️ <b>Security Alert:</b>\n• Source: VA Inquiry %s\n• IP Address: <code>%s</code>\n• Reason: <code>%s</code>", partnerCode, c.ClientIP(), err.Error()))
+			sc.respondError(c, err)
+			return
+		}
+
+		var request snapVAInquiryRequest
+		if err := helpers.DecodeJSON(body, &request); err != nil {
+			sc.respondError(c, snap.BadRequest())
+			return
+		}
+		virtualAccountNo := strings.ReplaceAll(request.VirtualAccountNo, " ", "")
+		if virtualAccountNo == "" {
+			sc.respondError(c, snap.InvalidMandatoryField("virtualAccountNo"))
+			return
+		}
+
+		loc, err := time.LoadLocation("Asia/Jakarta")
+		if err != nil {
+			loc = time.UTC
+		}
+		if err := helpers.CheckExternalID(sc.externalIDRepo, partnerCode, headers.ExternalIDDay(loc), headers.ExternalID); err != nil {
+			if errors.Is(err, helpers.ErrDuplicateExternalID) {
+				sc.respondError(c, snap.Conflict())
+				return
+			}
+			sc.sendAlert(fmt.Sprintf("❌ <b>Error Checking External ID</b>\n\n• Source: VA Inquiry %s\n• External ID: <code>%s</code>\n• Error: <code>%s</code>", partnerCode, headers.ExternalID, err.Error()))
+			sc.respondError(c, snap.GeneralError())
+			return
+		}
+
+		transaction, err := sc.transactionRepo.GetTransactionByBankNumber(virtualAccountNo)
+		if err == sql.ErrNoRows && request.CustomerNo != "" {
+			transaction, err = sc.transactionRepo.GetTransactionByBankNumber(strings.TrimSpace(request.CustomerNo))
+		}
+		if err == sql.ErrNoRows {
+			sc.respondError(c, snap.BillNotFound())
+			return
+		}
+		if err != nil {
+			sc.sendAlert(fmt.Sprintf("❌ <b>Error VA Inquiry</b>\n\n• Source: VA Inquiry %s\n• VA Number: <code>%s</code>\n• Error: <code>%s</code>", partnerCode, virtualAccountNo, err.Error()))
+			sc.respondError(c, snap.GeneralError())
+			return
+		}
+
+		if inquiryErr := sc.checkPayable(partnerCode, transaction); inquiryErr != nil {
+			sc.respondError(c, inquiryErr)
+			return
+		}
+
+		status, response := snap.SuccessResponse(snap.ServiceVAInquiry, map[string]interface{}{
+			"virtualAccountData": map[string]interface{}{
+				"inquiryStatus": "00",
+				"inquiryReason": map[string]string{
+					"english":   "Success",
+					"indonesia": "Sukses",
+				},
+				"partnerServiceId":   request.PartnerServiceID,
+				"customerNo":         request.CustomerNo,
+				"virtualAccountNo":   request.VirtualAccountNo,
+				"virtualAccountName": sc.customerName(partnerCode, transaction),
+				"inquiryRequestId":   request.InquiryRequestID,
+				"totalAmount": map[string]string{
+					"value":    transaction.Amount.String(),
+					"currency": "IDR",
+				},
+			},
+		})
+		c.JSON(status, response)
+	}
+}
+
+// checkPayable rejects virtual accounts that were already paid or can no longer be paid
+// app_transactions_infos.status holds helpers.StatusOutcome.TransactionStatus values
+func (sc *SnapController) checkPayable(partnerCode string, transaction *models.TransactionInfo) *snap.Error {
+	switch strings.ToLower(transaction.Status) {
+	case "success", "refunded":
+		return snap.PaidBill()
+	case "expires":
+		return snap.ExpiredBill()
+	}
+
+	partner := config.GetSnapPartner(partnerCode)
+	if partner != nil && transaction.CreatedAt != nil && time.Since(*transaction.CreatedAt) > partner.VAExpiry {
+		return snap.ExpiredBill()
+	}
+	return nil
+}
+
+// customerName returns the payer name stored for the virtual account (app_transactions_infos.customer_name)
+// A VA created without one is answered with the merchant's business name and alerted, so the missing data is fixed upstream
+func (sc *SnapController) customerName(partnerCode string, transaction *models.TransactionInfo) string {
+	if transaction.CustomerName != nil && strings.TrimSpace(*transaction.CustomerName) != "" {
+		return strings.TrimSpace(*transaction.CustomerName)
+	}
+
+	name := "KytaPay"
+	merchantPayment, err := sc.merchantRepo.GetMerchantPaymentByGatewayRef(transaction.GrantID)
+	if err == nil && merchantPayment.MerchantID != nil {
+		if merchant, err := sc.merchantRepo.GetMerchantByID(*merchantPayment.MerchantID); err == nil && merchant.BusinessName != "" {
+			name = merchant.BusinessName
+		}
+	}
+
+	now := time.Now()
+	if last, ok := lastCustomerNameAlerts.Load(partnerCode); !ok || now.Sub(last.(time.Time)) >= customerNameAlertInterval {
+		lastCustomerNameAlerts.Store(partnerCode, now)
+		sc.sendAlert(fmt.Sprintf("⚠️ <b>VA Without Customer Name</b>\n\n• Source: VA Inquiry %s\n• Payment ID: <code>%s</code>\n• Answered With: %s\n• Action: Store customer_name when the VA is created (alerted at most once per hour per partner)", partnerCode, transaction.GrantID, name))
+	}
+	return name
+}
+
+// respondError answers with the SNAP response code of err
+func (sc *SnapController) respondError(c *gin.Context, err error) {
+	var snapErr *snap.Error
+	if !errors.As(err, &snapErr) {
+		snapErr = snap.GeneralError()
+	}
+	status, response := snap.Response(snapErr, snap.ServiceVAInquiry)
+	c.JSON(status, response)
+}
+
+// sendAlert sends alert to Telegram
+func (sc *SnapController) sendAlert(message string) {
+	_ = sc.telegramService.SendMessage(message, "HTML")
+}
@@ -1,16 +1,13 @@
 package controllers
 
 import (
+	"context"
 	"database/sql"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
-	"net/http"
-	"strconv"
 	"strings"
-	"time"
 
-	"github.com/gin-gonic/gin"
+	"github.com/kytapay/webhook-v2/config"
 	"github.com/kytapay/webhook-v2/helpers"
 	"github.com/kytapay/webhook-v2/models"
 	"github.com/kytapay/webhook-v2/repositories"
@@ -23,321 +20,179 @@ type WebhookController struct {
 	merchantRepo        *repositories.MerchantRepository
 	walletRepo          *repositories.WalletRepository
 	feesRepo            *repositories.FeesRepository
-	callbackRepo        *repositories.CallbackRepository
 	userRepo            *repositories.UserRepository
+	ledgerRepo          *repositories.LedgerRepository
+	webhookEventRepo    *repositories.WebhookEventRepository
+	webhookNonceRepo    *repositories.WebhookNonceRepository
+	snapExternalIDRepo  *repositories.SnapExternalIDRepository
 	telegramService     *services.TelegramService
-	callbackService     *services.CallbackService
+	callbackDelivery    *services.CallbackDeliveryService
+	eventWorker         *services.WebhookWorker
 }
 
 func NewWebhookController(db *sql.DB) *WebhookController {
-	return &WebhookController{
+	wc := &WebhookController{
 		db:              db,
 		transactionRepo: repositories.NewTransactionRepository(db),
 		merchantRepo:    repositories.NewMerchantRepository(db),
 		walletRepo:      repositories.NewWalletRepository(db),
 		feesRepo:        repositories.NewFeesRepository(db),
-		callbackRepo:    repositories.NewCallbackRepository(db),
 		userRepo:        repositories.NewUserRepository(db),
+		ledgerRepo:      repositories.NewLedgerRepository(db),
+		webhookEventRepo: repositories.NewWebhookEventRepository(db),
+		webhookNonceRepo: repositories.NewWebhookNonceRepository(db),
+		snapExternalIDRepo: repositories.NewSnapExternalIDRepository(db),
 		telegramService: services.NewTelegramService(),
-		callbackService: services.NewCallbackService(),
+		callbackDelivery: services.NewCallbackDeliveryService(db),
 	}
+	wc.eventWorker = services.NewWebhookWorker(db, config.GetWorkerConfig(), wc.ProcessEvent)
+	return wc
 }
 
-// HandleLinkQuQRIS handles webhook callback from LinkQu for QRIS
-func (wc *WebhookController) HandleLinkQuQRIS(c *gin.Context) {
-	// Validasi client-id dan client-secret dari header
-	clientID := c.GetHeader("client-id")
-	clientSecret := c.GetHeader("client-secret")
-
-	if !helpers.VerifyLinkQuSignature(clientID, clientSecret) {
-		wc.sendTelegramAlert("🚨 <b>Unauthorized Callback Attempt</b>\n\n⚠️ <b>Security Alert:</b>\n• Source: QRIS LinkQu\n• IP Address: <code>" + c.ClientIP() + "</code>\n• Client ID: <code>" + clientID + "</code>", "HTML")
-		c.JSON(http.StatusOK, gin.H{
-			"responseCode":    "2002800",
-			"responseMessage": "Successful",
-		})
-		return
-	}
-
-	// Read request body
-	body, err := io.ReadAll(c.Request.Body)
-	if err != nil {
-		c.JSON(http.StatusOK, gin.H{
-			"responseCode":    "2002800",
-			"responseMessage": "Successful",
-		})
-		return
-	}
-
-	var data map[string]interface{}
-	if err := json.Unmarshal(body, &data); err != nil {
-		c.JSON(http.StatusOK, gin.H{
-			"responseCode":    "2002800",
-			"responseMessage": "Successful",
-		})
-		return
-	}
-
-	// Extract data
-	partnerRef, _ := data["partner_reff"].(string)
-	status, _ := data["status"].(string)
-	amount, _ := data["amount"].(float64)
-	transactionTime, _ := data["transaction_time"].(string)
-	callbackType, _ := data["type"].(string)
-
-	if partnerRef == "" {
-		wc.sendTelegramAlert("⚠️ <b>Callback Error</b>\n\n• Source: QRIS LinkQu\n• Issue: Missing payment ID", "HTML")
-		c.JSON(http.StatusOK, gin.H{
-			"responseCode":    "2002800",
-			"responseMessage": "Successful",
-		})
-		return
-	}
-
-	// Check callback type
-	if strings.ToUpper(callbackType) == "SETTLE" {
-		// Settlement notification - only send Telegram
-		wc.sendSettlementNotification(partnerRef, amount, transactionTime, "QRIS", "LinkQu")
-		c.JSON(http.StatusOK, gin.H{
-			"responseCode":    "2002800",
-			"responseMessage": "Successful",
-		})
-		return
-	}
-
-	// Process transaction (type = "pay")
-	wc.processTransaction(partnerRef, status, amount, transactionTime, "QRIS", "LinkQu")
-
-	// Always return 200 OK with success response
-	c.JSON(http.StatusOK, gin.H{
-		"responseCode":    "2002800",
-		"responseMessage": "Successful",
-	})
+// EventWorker returns the worker that processes queued callbacks
+func (wc *WebhookController) EventWorker() *services.WebhookWorker {
+	return wc.eventWorker
 }
 
-// HandleLinkQuEWallet handles webhook callback from LinkQu for E-Wallet
-func (wc *WebhookController) HandleLinkQuEWallet(c *gin.Context) {
-	// Validasi client-id dan client-secret dari header
-	clientID := c.GetHeader("client-id")
-	clientSecret := c.GetHeader("client-secret")
-
-	if !helpers.VerifyLinkQuSignature(clientID, clientSecret) {
-		wc.sendTelegramAlert("🚨 <b>Unauthorized Callback Attempt</b>\n\n⚠️ <b>Security Alert:</b>\n• Source: E-Wallet LinkQu\n• IP Address: <code>" + c.ClientIP() + "</code>\n• Client ID: <code>" + clientID + "</code>", "HTML")
-		c.JSON(http.StatusOK, gin.H{
-			"responseCode":    "2002800",
-			"responseMessage": "Successful",
-		})
-		return
-	}
-
-	// Read request body
-	body, err := io.ReadAll(c.Request.Body)
-	if err != nil {
-		c.JSON(http.StatusOK, gin.H{
-			"responseCode":    "2002800",
-			"responseMessage": "Successful",
-		})
-		return
-	}
-
-	var data map[string]interface{}
-	if err := json.Unmarshal(body, &data); err != nil {
-		c.JSON(http.StatusOK, gin.H{
-			"responseCode":    "2002800",
-			"responseMessage": "Successful",
-		})
-		return
-	}
-
-	// Extract data
-	partnerRef, _ := data["partner_reff"].(string)
-	status, _ := data["status"].(string)
-	amount, _ := data["amount"].(float64)
-	transactionTime, _ := data["transaction_time"].(string)
-	callbackType, _ := data["type"].(string)
-
-	if partnerRef == "" {
-		wc.sendTelegramAlert("⚠️ <b>Callback Error</b>\n\n• Source: E-Wallet LinkQu\n• Issue: Missing payment ID", "HTML")
-		c.JSON(http.StatusOK, gin.H{
-			"responseCode":    "2002800",
-			"responseMessage": "Successful",
-		})
-		return
-	}
-
-	// Check callback type
-	if strings.ToUpper(callbackType) == "SETTLE" {
-		// Settlement notification - only send Telegram
-		wc.sendSettlementNotification(partnerRef, amount, transactionTime, "E-Wallet", "LinkQu")
-		c.JSON(http.StatusOK, gin.H{
-			"responseCode":    "2002800",
-			"responseMessage": "Successful",
-		})
-		return
-	}
-
-	// Process transaction (type = "pay")
-	wc.processTransaction(partnerRef, status, amount, transactionTime, "EWALLET", "LinkQu")
-
-	// Always return 200 OK with success response
-	c.JSON(http.StatusOK, gin.H{
-		"responseCode":    "2002800",
-		"responseMessage": "Successful",
-	})
+// CallbackDelivery returns the service that delivers and retries merchant callbacks
+func (wc *WebhookController) CallbackDelivery() *services.CallbackDeliveryService {
+	return wc.callbackDelivery
 }
 
-// HandlePakaiLinkVA handles webhook callback from PakaiLink for Virtual Account
-// Note: PakaiLink VA does not send X-SIGNATURE or X-TIMESTAMP headers
-func (wc *WebhookController) HandlePakaiLinkVA(c *gin.Context) {
-	// Read request body
-	body, err := io.ReadAll(c.Request.Body)
-	if err != nil {
-		c.JSON(http.StatusOK, gin.H{
-			"responseCode":    "2002800",
-			"responseMessage": "Successful",
-		})
-		return
-	}
-
-	var requestData map[string]interface{}
-	if err := json.Unmarshal(body, &requestData); err != nil {
-		c.JSON(http.StatusOK, gin.H{
-			"responseCode":    "2002800",
-			"responseMessage": "Successful",
-		})
-		return
-	}
-
-	// Extract transactionData
-	transactionData, ok := requestData["transactionData"].(map[string]interface{})
-	if !ok {
-		c.JSON(http.StatusOK, gin.H{
-			"responseCode":    "2002800",
-			"responseMessage": "Successful",
-		})
-		return
-	}
-
-	// Extract data
-	partnerRef, _ := transactionData["partnerReferenceNo"].(string)
-	paymentFlagStatus, _ := transactionData["paymentFlagStatus"].(string)
-	callbackType, _ := transactionData["callbackType"].(string)
-	paidAmount, _ := transactionData["paidAmount"].(map[string]interface{})
-	
-	var amount float64
-	if paidAmount != nil {
-		if valueStr, ok := paidAmount["value"].(string); ok {
-			if valueFloat, err := strconv.ParseFloat(valueStr, 64); err == nil {
-				amount = valueFloat
-			}
-		}
-	}
-
-	// Map paymentFlagStatus to status
-	status := "PENDING"
-	if paymentFlagStatus == "00" {
-		status = "SUCCESS"
-	}
-
-	// Use current time as date (PakaiLink VA doesn't send X-TIMESTAMP)
-	loc, err := time.LoadLocation("Asia/Jakarta")
+// processTransaction processes the transaction update
+// All database writes run inside a single transaction; merchant callback and
+// Telegram notification are only sent after the transaction is committed
+// amountPolicy is the operator override of AMOUNT_MISMATCH_POLICY set on requeue, empty uses the configured policy
+func (wc *WebhookController) processTransaction(ctx context.Context, paymentID, status string, amount models.Money, date, source, provider, channel, amountPolicy string) error {
+	// Map the provider status before touching anything; unmapped statuses are held for review
+	outcome, err := wc.resolveStatus(provider, channel, source, paymentID, status)
 	if err != nil {
-		loc = time.UTC
-	}
-	date := time.Now().In(loc).Format("2006-01-02T15:04:05Z07:00")
-
-	if partnerRef == "" {
-		wc.sendTelegramAlert("⚠️ <b>Callback Error</b>\n\n• Source: VA PakaiLink\n• Issue: Missing payment ID", "HTML")
-		c.JSON(http.StatusOK, gin.H{
-			"responseCode":    "2002800",
-			"responseMessage": "Successful",
-		})
-		return
-	}
-
-	// Check callback type
-	if strings.ToLower(callbackType) == "settlement" {
-		// Settlement notification - only send Telegram
-		wc.sendSettlementNotification(partnerRef, amount, date, "Virtual Account", "PakaiLink")
-		c.JSON(http.StatusOK, gin.H{
-			"responseCode":    "2002800",
-			"responseMessage": "Successful",
-		})
-		return
+		return err
 	}
 
-	// Process transaction (callbackType = "payment")
-	wc.processTransaction(partnerRef, status, amount, date, "VA", "PakaiLink")
-
-	// Always return 200 OK with success response
-	c.JSON(http.StatusOK, gin.H{
-		"responseCode":    "2002800",
-		"responseMessage": "Successful",
-	})
-}
-
-// processTransaction processes the transaction update
-func (wc *WebhookController) processTransaction(paymentID, status string, amount float64, date, source, provider string) {
 	// Get transaction
 	transaction, err := wc.transactionRepo.GetTransactionByGrantID(paymentID)
 	if err != nil {
 		formattedAmount := helpers.FormatNumber(amount, 0)
 		wc.sendTelegramAlert(fmt.Sprintf("ℹ️ <b>Transaction Not Found</b>\n\n• Source: %s\n• Payment ID: <code>%s</code>\n• Status: %s\n• Amount: Rp %s\n• Date: %s", source, paymentID, status, formattedAmount, date), "HTML")
-		return
+		return fmt.Errorf("transaction not found: %v", err)
 	}
 
-	// Get merchant payment
-	merchantPayment, err := wc.merchantRepo.GetMerchantPaymentByGatewayRef(paymentID)
+	// Begin database transaction for the whole settlement
+	tx, err := wc.db.BeginTx(ctx, nil)
+	if err != nil {
+		wc.sendTelegramAlert(fmt.Sprintf("❌ <b>Error Starting Database Transaction</b>\n\n• Source: %s\n• Payment ID: <code>%s</code>\n• Error: <code>%s</code>", source, paymentID, err.Error()), "HTML")
+		return err
+	}
+	// Release the row locks on every early return or panic; a no-op once the transaction is committed
+	defer tx.Rollback()
+	transactionRepo := wc.transactionRepo.WithTx(tx)
+	merchantRepo := wc.merchantRepo.WithTx(tx)
+	walletRepo := wc.walletRepo.WithTx(tx)
+	feesRepo := wc.feesRepo.WithTx(tx)
+	ledgerRepo := wc.ledgerRepo.WithTx(tx)
+
+	// Get merchant payment (row stays locked until commit so concurrent callbacks are serialized)
+	merchantPayment, err := merchantRepo.GetMerchantPaymentByGatewayRefForUpdate(paymentID)
 	if err != nil {
+		_ = tx.Rollback()
 		formattedAmount := helpers.FormatNumber(amount, 0)
 		wc.sendTelegramAlert(fmt.Sprintf("ℹ️ <b>Merchant Transaction Not Found</b>\n\n• Source: %s\n• Payment ID: <code>%s</code>\n• Status: %s\n• Amount: Rp %s\n• Date: %s", source, paymentID, status, formattedAmount, date), "HTML")
-		return
+		return fmt.Errorf("merchant payment not found: %v", err)
 	}
-
-	// Check if already processed
-	if merchantPayment.Status != "Pending" {
-		wc.sendTelegramAlert(fmt.Sprintf("⚠️ <b>Duplicate Callback Prevented</b>\n\n• Source: %s\n• Payment ID: <code>%s</code>\n• Current Status: <b>%s</b>\n• Attempted Status: %s", source, paymentID, merchantPayment.Status, status), "HTML")
-		return
+	if merchantPayment.MerchantID == nil || merchantPayment.PaymentMethodID == nil {
+		return wc.rollbackWithAlert(tx, "Error Incomplete Merchant Payment", source, paymentID, fmt.Errorf("merchant payment has no merchant_id or payment_method_id"))
 	}
 
 	// Normalize status
-	normalizedStatus := helpers.NormalizeStatus(status)
-	merchantNormalizedStatus := helpers.MerchantNormalizeStatus(status)
+	normalizedStatus := outcome.TransactionStatus()
+	merchantNormalizedStatus := outcome.MerchantStatus()
+
+	// Get transactions record
+	transactions, err := transactionRepo.GetTransactionsByGrantID(paymentID)
+	if err != nil {
+		return wc.rollbackWithAlert(tx, "Error Getting Transactions", source, paymentID, err)
+	}
+	transactionsStatus := ""
+	if transactions != nil {
+		transactionsStatus = transactions.Status
+	}
+
+	// Check the merchant payment and transactions transitions; a repeated status is a duplicate callback
+	plan, from, err := planPayment(merchantPayment.Status, transactionsStatus, outcome, source, merchantPayment.PaymentMethodID)
+	if err != nil {
+		return wc.rejectTransition(tx, source, paymentID, from, status, err)
+	}
+	paymentStatus := plan.transactionsStatus
+
+	// Compare the paid amount with the order amount; from here on amount is the amount to settle.
+	// The stored order amount is never overwritten, the paid amount is only kept in paid_amount
+	paidAmount := amount
+	amount = transaction.Amount
+	amountStatus := ""
+	if merchantNormalizedStatus == models.LifecycleSuccess {
+		amountCheck := *config.GetAmountCheckConfig()
+		if amountPolicy != "" {
+			amountCheck.Policy = amountPolicy
+		}
+		amount, amountStatus = checkAmount(transaction.Amount, paidAmount, &amountCheck)
+	}
+
+	if amountStatus == models.AmountStatusRejected {
+		_ = tx.Rollback()
+		if err := wc.transactionRepo.RecordAmountCheck(paymentID, paidAmount, amountStatus); err != nil {
+			wc.sendTelegramAlert(fmt.Sprintf("❌ <b>Error Recording Amount Check</b>\n\n• Source: %s\n• Payment ID: <code>%s</code>\n• Error: <code>%s</code>", source, paymentID, err.Error()), "HTML")
+		}
+		wc.sendAmountMismatchAlert(source, paymentID, transaction.Amount, paidAmount, amountStatus)
+		return fmt.Errorf("%w: paid amount %s differs from order amount %s", services.ErrEventHeld, paidAmount, transaction.Amount)
+	}
 
 	// Update transaction
-	err = wc.transactionRepo.UpdateTransaction(paymentID, normalizedStatus, int64(amount))
+	err = transactionRepo.UpdateTransaction(paymentID, normalizedStatus, transaction.Amount)
 	if err != nil {
-		wc.sendTelegramAlert(fmt.Sprintf("❌ <b>Error Updating Transaction</b>\n\n• Source: %s\n• Payment ID: <code>%s</code>\n• Error: <code>%s</code>", source, paymentID, err.Error()), "HTML")
-		return
+		return wc.rollbackWithAlert(tx, "Error Updating Transaction", source, paymentID, err)
 	}
 
-	// Get transactions record
-	transactions, _ := wc.transactionRepo.GetTransactionsByGrantID(paymentID)
+	// Update merchant payment
+	err = merchantRepo.UpdateMerchantPayment(paymentID, merchantNormalizedStatus, merchantPayment.Amount)
+	if err != nil {
+		return wc.rollbackWithAlert(tx, "Error Updating Merchant Payment", source, paymentID, err)
+	}
 
-	if transactions == nil {
-		// Update merchant payment only
-		err = wc.merchantRepo.UpdateMerchantPayment(paymentID, merchantNormalizedStatus, amount)
+	if amountStatus != "" {
+		err = transactionRepo.RecordAmountCheck(paymentID, paidAmount, amountStatus)
 		if err != nil {
-			wc.sendTelegramAlert(fmt.Sprintf("❌ <b>Error Updating Merchant Payment</b>\n\n• Source: %s\n• Payment ID: <code>%s</code>\n• Error: <code>%s</code>", source, paymentID, err.Error()), "HTML")
-			return
+			return wc.rollbackWithAlert(tx, "Error Recording Amount Check", source, paymentID, err)
 		}
-	} else {
+	}
+
+	if transactions != nil {
 		// Update transactions
-		err = wc.transactionRepo.UpdateTransactions(paymentID, merchantNormalizedStatus, merchantNormalizedStatus)
+		err = transactionRepo.UpdateTransactions(paymentID, merchantNormalizedStatus, paymentStatus)
 		if err != nil {
-			wc.sendTelegramAlert(fmt.Sprintf("❌ <b>Error Updating Transactions</b>\n\n• Source: %s\n• Payment ID: <code>%s</code>\n• Error: <code>%s</code>", source, paymentID, err.Error()), "HTML")
-			return
+			return wc.rollbackWithAlert(tx, "Error Updating Transactions", source, paymentID, err)
+		}
+
+		// Fees of a partial payment are recalculated on the amount actually paid (paid_amount)
+		if amountStatus == models.AmountStatusPartial {
+			transactions.Subtotal = amount
+			transactions.ChargePercentage = amount.Percent(transactions.Percentage)
+			transactions.Total = amount - transactions.ChargePercentage - transactions.ChargeFixed
+			err = transactionRepo.UpdateTransactionsAmounts(paymentID, transactions.Subtotal, transactions.ChargePercentage, transactions.Total)
+			if err != nil {
+				return wc.rollbackWithAlert(tx, "Error Updating Transactions", source, paymentID, err)
+			}
 		}
 	}
 
 	// Get merchant and fees
-	merchant, err := wc.merchantRepo.GetMerchantByID(*merchantPayment.MerchantID)
+	merchant, err := merchantRepo.GetMerchantByID(*merchantPayment.MerchantID)
 	if err != nil {
-		wc.sendTelegramAlert(fmt.Sprintf("❌ <b>Error Getting Merchant</b>\n\n• Source: %s\n• Payment ID: <code>%s</code>\n• Error: <code>%s</code>", source, paymentID, err.Error()), "HTML")
-		return
+		return wc.rollbackWithAlert(tx, "Error Getting Merchant", source, paymentID, err)
 	}
 
-	feeReguler, _ := wc.feesRepo.GetFeesLimit(10, *merchantPayment.PaymentMethodID)
-	// feeExpress, _ := wc.feesRepo.GetFeesExpress(10) // Not used in payment processing
+	feeReguler, _ := feesRepo.GetFeesLimit(10, *merchantPayment.PaymentMethodID)
+	// feeExpress, _ := feesRepo.GetFeesExpress(10) // Not used in payment processing
 
 	// Determine user_id
 	var userID int
@@ -347,65 +202,47 @@ func (wc *WebhookController) processTransaction(paymentID, status string, amount
 		userID = *transactions.UserID
 	}
 
-	// Get wallet
-	wallet, err := wc.walletRepo.GetUserWallet(userID)
-	if err != nil {
-		wc.sendTelegramAlert(fmt.Sprintf("❌ <b>Error Getting Wallet</b>\n\n• Source: %s\n• Payment ID: <code>%s</code>\n• Error: <code>%s</code>", source, paymentID, err.Error()), "HTML")
-		return
-	}
-
-	// Calculate charges
-	var chargePercentage, chargeFixed float64
+	// Calculate charges (percentage fee is rounded half away from zero to the cent)
+	var chargePercentage models.Rate
+	var chargeFixed models.Money
 	if feeReguler != nil {
 		chargePercentage = feeReguler.ChargePercentage
 		chargeFixed = feeReguler.ChargeFixed
 	} else {
-		chargePercentage = 5.0
-		chargeFixed = 5000.0
+		chargePercentage = models.MustParseRate("5")
+		chargeFixed = models.Rupiah(5000)
 	}
 
-	chargePercentageAmount := (amount * chargePercentage) / 100
+	chargePercentageAmount := amount.Percent(chargePercentage)
 	totalFee := chargePercentageAmount + chargeFixed
 
-	// Determine payment status
-	paymentStatus := merchantNormalizedStatus
-	vaRealtimePaymentMethods := []int{2, 4, 6, 8} // Assuming these are realtime VA methods
-	isRealtimeVA := false
-	for _, pmID := range vaRealtimePaymentMethods {
-		if merchantPayment.PaymentMethodID != nil && *merchantPayment.PaymentMethodID == pmID {
-			isRealtimeVA = true
-			break
+	// Update wallet balance for VA Success (non-realtime)
+	walletMoved := false
+	if plan.creditWallet {
+		gross, credit := amount, amount-totalFee
+		if transactions != nil {
+			gross, credit = transactions.Subtotal, transactions.Total
 		}
-	}
 
-	if merchantNormalizedStatus == "Success" && !isRealtimeVA {
-		// Check if payment method requires settlement
-		settlementMethods := []int{1, 2, 4, 6, 8, 11, 12, 13, 14, 15, 19}
-		for _, pmID := range settlementMethods {
-			if merchantPayment.PaymentMethodID != nil && *merchantPayment.PaymentMethodID == pmID {
-				paymentStatus = "Pending_Settlement"
-				break
-			}
+		err = wc.creditPayment(ledgerRepo, walletRepo, userID, paymentID, source, provider, gross, credit)
+		if err != nil {
+			return wc.rollbackWithAlert(tx, "Error Crediting Wallet", source, paymentID, err)
 		}
+		walletMoved = true
 	}
 
-	// Update wallet balance for VA Success (non-realtime)
-	if source == "VA" && merchantNormalizedStatus == "Success" && !isRealtimeVA {
-		if transactions == nil {
-			newBalance := wallet.Balance + (amount - totalFee)
-			err = wc.walletRepo.UpdateWalletBalance(userID, newBalance)
-			if err != nil {
-				wc.sendTelegramAlert(fmt.Sprintf("❌ <b>Error Updating Wallet</b>\n\n• Source: %s\n• Payment ID: <code>%s</code>\n• Error: <code>%s</code>", source, paymentID, err.Error()), "HTML")
-				return
-			}
-		} else {
-			newBalance := wallet.Balance + transactions.Total
-			err = wc.walletRepo.UpdateWalletBalance(userID, newBalance)
-			if err != nil {
-				wc.sendTelegramAlert(fmt.Sprintf("❌ <b>Error Updating Wallet</b>\n\n• Source: %s\n• Payment ID: <code>%s</code>\n• Error: <code>%s</code>", source, paymentID, err.Error()), "HTML")
-				return
-			}
+	// Refund: take back whatever this payment credited
+	if models.HasEffect(plan.effects, models.EffectReverseFunds) {
+		// Lock wallet row so balance changes from other callbacks wait for this transaction
+		wallet, err := walletRepo.GetUserWalletForUpdate(userID)
+		if err != nil {
+			return wc.rollbackWithAlert(tx, "Error Getting Wallet", source, paymentID, err)
 		}
+		moved, err := wc.reverseFunds(ledgerRepo, walletRepo, userID, wallet.Balance, paymentID, source+" payment refunded")
+		if err != nil {
+			return wc.rollbackWithAlert(tx, "Error Reversing Funds", source, paymentID, err)
+		}
+		walletMoved = walletMoved || moved
 	}
 
 	// Create transaction record if not exists
@@ -432,15 +269,27 @@ func (wc *WebhookController) processTransaction(paymentID, status string, amount
 			Status:                paymentStatus,
 		}
 
-		err = wc.transactionRepo.CreateTransaction(transactionsData)
+		err = transactionRepo.CreateTransaction(transactionsData)
 		if err != nil {
-			wc.sendTelegramAlert(fmt.Sprintf("❌ <b>Error Creating Transaction</b>\n\n• Source: %s\n• Payment ID: <code>%s</code>\n• Error: <code>%s</code>", source, paymentID, err.Error()), "HTML")
-			return
+			return wc.rollbackWithAlert(tx, "Error Creating Transaction", source, paymentID, err)
 		}
 	}
 
-	// Send callback to merchant (only for payment callbacks, not settlement)
-	if transactions == nil {
+	// Commit all changes at once
+	if err := tx.Commit(); err != nil {
+		return wc.rollbackWithAlert(tx, "Error Committing Transaction", source, paymentID, err)
+	}
+
+	if walletMoved {
+		wc.verifyWalletLedger(userID, source, paymentID)
+	}
+
+	if amountStatus == models.AmountStatusMismatch || amountStatus == models.AmountStatusPartial {
+		wc.sendAmountMismatchAlert(source, paymentID, transaction.Amount, paidAmount, amountStatus)
+	}
+
+	// Send callback to merchant
+	if models.HasEffect(plan.effects, models.EffectNotifyMerchant) {
 		// Use merchantNormalizedStatus (Success/Pending/Failed) instead of normalizedStatus (success/pending/expires)
 		payloads := services.BuildPayloadV2(transaction, paymentID, merchant.BusinessName, merchantNormalizedStatus, date)
 		payload := payloads[transaction.PaymentMethod]
@@ -452,497 +301,547 @@ func (wc *WebhookController) processTransaction(paymentID, status string, amount
 	message := fmt.Sprintf("✅ <b>Pembayaran Berhasil</b>\n\n📋 <b>Detail Transaksi:</b>\n• ID Transaksi: <code>%s</code>\n• Order ID: <code>%s</code>\n• Metode: %s\n• Provider: %s\n• Jumlah: <b>Rp %s</b>\n• Status: <b>%s</b>\n• Waktu: %s",
 		paymentID, transaction.OrderID, getPaymentMethodName(source), provider, formattedAmount, normalizedStatus, date)
 	wc.sendTelegramAlert(message, "HTML")
+
+	return nil
 }
 
-// sendSettlementNotification sends settlement notification to Telegram
-func (wc *WebhookController) sendSettlementNotification(paymentID string, amount float64, date string, paymentMethod, provider string) {
-	// Get transaction for additional info
-	transaction, err := wc.transactionRepo.GetTransactionByGrantID(paymentID)
-	if err != nil {
-		// If transaction not found, send basic notification
-		formattedAmount := helpers.FormatNumber(amount, 0)
-		message := fmt.Sprintf("💰 <b>Settlement Notification</b>\n\n📋 <b>Detail:</b>\n• ID Transaksi: <code>%s</code>\n• Metode: %s\n• Provider: %s\n• Jumlah: <b>Rp %s</b>\n• Waktu: %s",
-			paymentID, paymentMethod, provider, formattedAmount, date)
-		wc.sendTelegramAlert(message, "HTML")
-		return
+// isRealtimeVA reports whether the payment method is a realtime VA, which skips Pending_Settlement
+func isRealtimeVA(paymentMethodID *int) bool {
+	vaRealtimePaymentMethods := []int{2, 4, 6, 8} // Assuming these are realtime VA methods
+	for _, pmID := range vaRealtimePaymentMethods {
+		if paymentMethodID != nil && *paymentMethodID == pmID {
+			return true
+		}
 	}
-
-	formattedAmount := helpers.FormatNumber(amount, 0)
-	message := fmt.Sprintf("💰 <b>Settlement Berhasil</b>\n\n📋 <b>Detail Transaksi:</b>\n• ID Transaksi: <code>%s</code>\n• Order ID: <code>%s</code>\n• Metode: %s\n• Provider: %s\n• Jumlah: <b>Rp %s</b>\n• Waktu Settlement: %s",
-		paymentID, transaction.OrderID, paymentMethod, provider, formattedAmount, date)
-	wc.sendTelegramAlert(message, "HTML")
+	return false
 }
 
-// getPaymentMethodName returns formatted payment method name
-func getPaymentMethodName(source string) string {
-	switch source {
-	case "QRIS":
-		return "QRIS"
-	case "EWALLET":
-		return "E-Wallet"
-	case "VA":
-		return "Virtual Account"
-	default:
-		return source
-	}
+// creditedAtPayment reports whether the merchant wallet is credited by the payment callback
+// (VA non-realtime) rather than by the settlement callback
+func creditedAtPayment(source string, paymentMethodID *int) bool {
+	return source == "VA" && !isRealtimeVA(paymentMethodID)
 }
 
+// paymentPlan is what a payment callback changes, decided from the stored statuses before any write
+type paymentPlan struct {
+	// effects are the side effects of the merchant_payments transition
+	effects []string
+	// transactionsStatus is the new transactions.status, Pending_Settlement for methods settled by a later callback
+	transactionsStatus string
+	// creditWallet is set when this callback credits the merchant wallet
+	creditWallet bool
+}
 
-// sendCallbackToMerchant sends callback to merchant
-func (wc *WebhookController) sendCallbackToMerchant(transaction *models.TransactionInfo, payload interface{}) {
-	_, err := wc.callbackRepo.GetCallbackByTransactionInfoID(transaction.ID)
+// planPayment checks the merchant_payments and transactions transitions of a payment callback
+// transactionsStatus is empty when the payment has no transactions row yet. On error the status
+// the rejected transition started from is returned with it
+func planPayment(paymentStatus, transactionsStatus string, outcome helpers.StatusOutcome, source string, paymentMethodID *int) (*paymentPlan, string, error) {
+	merchantStatus := outcome.MerchantStatus()
+	effects, err := models.PaymentLifecycle.Transition(paymentStatus, merchantStatus)
 	if err != nil {
-		return
+		return nil, paymentStatus, err
 	}
 
-	statusCode, responseBody, err := wc.callbackService.SendCallback(transaction.NotifyURL, payload, transaction.Token)
-	if err != nil {
-		errorMessage := "408 - Request Timeout"
-		if err.Error() != "" {
-			errorMessage = err.Error()
+	plan := &paymentPlan{effects: effects, transactionsStatus: merchantStatus}
+	if outcome.Captured() {
+		// A capture is settled by its own settlement callback, whatever the payment method
+		plan.transactionsStatus = models.LifecyclePendingSettlement
+	} else if merchantStatus == models.LifecycleSuccess && !isRealtimeVA(paymentMethodID) {
+		// Check if payment method requires settlement
+		settlementMethods := []int{1, 2, 4, 6, 8, 11, 12, 13, 14, 15, 19}
+		for _, pmID := range settlementMethods {
+			if paymentMethodID != nil && *paymentMethodID == pmID {
+				plan.transactionsStatus = models.LifecyclePendingSettlement
+				break
+			}
 		}
-		wc.callbackRepo.UpdateCallback(transaction.ID, "Failed", errorMessage, responseBody, payload)
-		wc.sendTelegramAlert(fmt.Sprintf("❌ <b>Request Timeout (Callback)</b>\n\n• Error: <code>%s</code>\n• URL: <code>%s</code>", err.Error(), transaction.NotifyURL), "HTML")
-		return
 	}
 
-	if statusCode >= 200 && statusCode < 300 {
-		wc.callbackRepo.UpdateCallback(transaction.ID, "Success", fmt.Sprintf("%d - Success", statusCode), responseBody, payload)
-	} else {
-		errorMessage := fmt.Sprintf("HTTP %d", statusCode)
-		switch statusCode {
-		case 400:
-			errorMessage = "400 - Bad Request"
-		case 401:
-			errorMessage = "401 - Unauthorized"
-		case 403:
-			errorMessage = "403 - Forbidden"
-		case 404:
-			errorMessage = "404 - Not Found"
-		case 422:
-			errorMessage = "422 - Unprocessable Entity"
-		case 500:
-			errorMessage = "500 - Internal Server Error"
-		case 502:
-			errorMessage = "502 - Bad Gateway"
-		case 503:
-			errorMessage = "503 - Service Unavailable"
+	// The transactions row follows its own state machine (e.g. Pending -> Pending_Settlement)
+	if transactionsStatus != "" {
+		if _, err := models.TransactionLifecycle.Transition(transactionsStatus, plan.transactionsStatus); err != nil && !errors.Is(err, models.ErrSameStatus) {
+			return nil, transactionsStatus, err
 		}
-		wc.callbackRepo.UpdateCallback(transaction.ID, "Failed", errorMessage, responseBody, payload)
 	}
-}
 
-// sendTelegramAlert sends alert to Telegram
-func (wc *WebhookController) sendTelegramAlert(message, parseMode string) {
-	_ = wc.telegramService.SendMessage(message, parseMode)
+	plan.creditWallet = models.HasEffect(effects, models.EffectMoveFunds) && creditedAtPayment(source, paymentMethodID)
+	return plan, "", nil
 }
 
-// HandleLinkQuPayoutBank handles webhook callback from LinkQu for Bank Payout
-func (wc *WebhookController) HandleLinkQuPayoutBank(c *gin.Context) {
-	// Validasi client-id dan client-secret dari header
-	clientID := c.GetHeader("client-id")
-	clientSecret := c.GetHeader("client-secret")
-
-	if !helpers.VerifyLinkQuSignature(clientID, clientSecret) {
-		wc.sendTelegramAlert("🚨 <b>Unauthorized Callback Attempt</b>\n\n⚠️ <b>Security Alert:</b>\n• Source: Bank Payout LinkQu\n• IP Address: <code>"+c.ClientIP()+"</code>\n• Client ID: <code>"+clientID+"</code>", "HTML")
-		c.JSON(http.StatusOK, gin.H{
-			"responseCode":    "2002800",
-			"responseMessage": "Successful",
-		})
-		return
-	}
+// errSettlementBeforePayment is returned for a settlement that overtook its payment callback; the event is retried
+var errSettlementBeforePayment = errors.New("settlement received before the payment succeeded")
 
-	// Read request body
-	body, err := io.ReadAll(c.Request.Body)
-	if err != nil {
-		c.JSON(http.StatusOK, gin.H{
-			"responseCode":    "2002800",
-			"responseMessage": "Successful",
-		})
-		return
+// planSettlement checks the transactions transition of a settlement callback against the stored statuses
+// and reports whether the settlement credits the merchant wallet
+func planSettlement(paymentStatus, transactionsStatus, source string, paymentMethodID *int) (bool, error) {
+	if transactionsStatus == "" || strings.EqualFold(paymentStatus, models.LifecyclePending) || strings.EqualFold(paymentStatus, models.LifecycleFailed) {
+		return false, fmt.Errorf("%w (merchant payment status %s)", errSettlementBeforePayment, paymentStatus)
 	}
 
-	var data map[string]interface{}
-	if err := json.Unmarshal(body, &data); err != nil {
-		c.JSON(http.StatusOK, gin.H{
-			"responseCode":    "2002800",
-			"responseMessage": "Successful",
-		})
-		return
+	if _, err := models.TransactionLifecycle.Transition(transactionsStatus, models.LifecycleSuccess); err != nil {
+		return false, err
 	}
 
-	// Extract data
-	partnerRef, _ := data["partner_reff"].(string)
-	status, _ := data["status"].(string)
-	amount, _ := data["amount"].(float64)
-	transactionTime, _ := data["transaction_time"].(string)
+	// Methods credited at payment time (VA non-realtime) must not be credited again,
+	// even when the payment predates the ledger and has no ledger rows
+	return !creditedAtPayment(source, paymentMethodID), nil
+}
 
-	if partnerRef == "" {
-		wc.sendTelegramAlert("⚠️ <b>Callback Error</b>\n\n• Source: Bank Payout LinkQu\n• Issue: Missing payment ID", "HTML")
-		c.JSON(http.StatusOK, gin.H{
-			"responseCode":    "2002800",
-			"responseMessage": "Successful",
-		})
-		return
+// processSettlement settles a payment that was parked in Pending_Settlement
+// The transactions row moves to Success, methods that defer crediting get the wallet credit and ledger
+// entries now, and the merchant is notified once the transaction is committed
+func (wc *WebhookController) processSettlement(ctx context.Context, paymentID string, amount models.Money, date, source, provider string) error {
+	// Get transaction
+	transaction, err := wc.transactionRepo.GetTransactionByGrantID(paymentID)
+	if err != nil {
+		formattedAmount := helpers.FormatNumber(amount, 0)
+		wc.sendTelegramAlert(fmt.Sprintf("ℹ️ <b>Settlement Transaction Not Found</b>\n\n• Source: %s\n• Payment ID: <code>%s</code>\n• Amount: Rp %s\n• Date: %s", source, paymentID, formattedAmount, date), "HTML")
+		return fmt.Errorf("transaction not found: %v", err)
 	}
 
-	// Process payout transaction
-	wc.processPayoutTransaction(partnerRef, status, amount, transactionTime, "Bank", "LinkQu")
+	// Begin database transaction for the whole settlement
+	tx, err := wc.db.BeginTx(ctx, nil)
+	if err != nil {
+		wc.sendTelegramAlert(fmt.Sprintf("❌ <b>Error Starting Database Transaction</b>\n\n• Source: %s\n• Payment ID: <code>%s</code>\n• Error: <code>%s</code>", source, paymentID, err.Error()), "HTML")
+		return err
+	}
+	// Release the row locks on every early return or panic; a no-op once the transaction is committed
+	defer tx.Rollback()
+	transactionRepo := wc.transactionRepo.WithTx(tx)
+	merchantRepo := wc.merchantRepo.WithTx(tx)
+	walletRepo := wc.walletRepo.WithTx(tx)
+	ledgerRepo := wc.ledgerRepo.WithTx(tx)
+
+	// Lock the merchant payment so the settlement is serialized with payment callbacks
+	merchantPayment, err := merchantRepo.GetMerchantPaymentByGatewayRefForUpdate(paymentID)
+	if err != nil {
+		return wc.rollbackWithAlert(tx, "Error Getting Merchant Payment", source, paymentID, err)
+	}
+	if merchantPayment.MerchantID == nil {
+		return wc.rollbackWithAlert(tx, "Error Incomplete Merchant Payment", source, paymentID, fmt.Errorf("merchant payment has no merchant_id"))
+	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"responseCode":    "2002800",
-		"responseMessage": "Successful",
-	})
-}
+	// A settlement can overtake the payment callback; retry until the payment is recorded
+	transactions, err := transactionRepo.GetTransactionsByGrantID(paymentID)
+	if err != nil {
+		return wc.rollbackWithAlert(tx, "Error Getting Transactions", source, paymentID, err)
+	}
+	transactionsStatus := ""
+	if transactions != nil {
+		transactionsStatus = transactions.Status
+	}
 
-// HandleLinkQuPayoutEWallet handles webhook callback from LinkQu for E-Wallet Payout
-func (wc *WebhookController) HandleLinkQuPayoutEWallet(c *gin.Context) {
-	// Validasi client-id dan client-secret dari header
-	clientID := c.GetHeader("client-id")
-	clientSecret := c.GetHeader("client-secret")
-
-	if !helpers.VerifyLinkQuSignature(clientID, clientSecret) {
-		wc.sendTelegramAlert("🚨 <b>Unauthorized Callback Attempt</b>\n\n⚠️ <b>Security Alert:</b>\n• Source: E-Wallet Payout LinkQu\n• IP Address: <code>"+c.ClientIP()+"</code>\n• Client ID: <code>"+clientID+"</code>", "HTML")
-		c.JSON(http.StatusOK, gin.H{
-			"responseCode":    "2002800",
-			"responseMessage": "Successful",
-		})
-		return
+	creditWallet, err := planSettlement(merchantPayment.Status, transactionsStatus, source, merchantPayment.PaymentMethodID)
+	if errors.Is(err, errSettlementBeforePayment) {
+		_ = tx.Rollback()
+		return err
+	}
+	if err != nil {
+		return wc.rejectTransition(tx, source, paymentID, transactionsStatus, "SETTLED", err)
 	}
 
-	// Read request body
-	body, err := io.ReadAll(c.Request.Body)
+	err = transactionRepo.UpdateTransactions(paymentID, models.LifecycleSuccess, models.LifecycleSuccess)
 	if err != nil {
-		c.JSON(http.StatusOK, gin.H{
-			"responseCode":    "2002800",
-			"responseMessage": "Successful",
-		})
-		return
+		return wc.rollbackWithAlert(tx, "Error Updating Transactions", source, paymentID, err)
 	}
 
-	var data map[string]interface{}
-	if err := json.Unmarshal(body, &data); err != nil {
-		c.JSON(http.StatusOK, gin.H{
-			"responseCode":    "2002800",
-			"responseMessage": "Successful",
-		})
-		return
+	merchant, err := merchantRepo.GetMerchantByID(*merchantPayment.MerchantID)
+	if err != nil {
+		return wc.rollbackWithAlert(tx, "Error Getting Merchant", source, paymentID, err)
 	}
+	userID := merchant.UserID
 
-	// Extract data
-	partnerRef, _ := data["partner_reff"].(string)
-	status, _ := data["status"].(string)
-	amount, _ := data["amount"].(float64)
-	transactionTime, _ := data["transaction_time"].(string)
+	walletMoved := false
+	if creditWallet {
+		err = wc.creditPayment(ledgerRepo, walletRepo, userID, paymentID, source, provider, transactions.Subtotal, transactions.Total)
+		if err != nil {
+			return wc.rollbackWithAlert(tx, "Error Crediting Wallet", source, paymentID, err)
+		}
+		walletMoved = true
+	}
 
-	if partnerRef == "" {
-		wc.sendTelegramAlert("⚠️ <b>Callback Error</b>\n\n• Source: E-Wallet Payout LinkQu\n• Issue: Missing payment ID", "HTML")
-		c.JSON(http.StatusOK, gin.H{
-			"responseCode":    "2002800",
-			"responseMessage": "Successful",
-		})
-		return
+	// Commit all changes at once
+	if err := tx.Commit(); err != nil {
+		return wc.rollbackWithAlert(tx, "Error Committing Transaction", source, paymentID, err)
 	}
 
-	// Process payout transaction
-	wc.processPayoutTransaction(partnerRef, status, amount, transactionTime, "E-Wallet", "LinkQu")
+	if walletMoved {
+		wc.verifyWalletLedger(userID, source, paymentID)
+	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"responseCode":    "2002800",
-		"responseMessage": "Successful",
-	})
+	// Send callback to merchant
+	payloads := services.BuildPayloadV2(transaction, paymentID, merchant.BusinessName, models.CallbackPaymentSettled, date)
+	wc.sendCallbackToMerchant(transaction, payloads[transaction.PaymentMethod])
+
+	// Send Telegram notification
+	formattedAmount := helpers.FormatNumber(amount, 0)
+	message := fmt.Sprintf("💰 <b>Settlement Berhasil</b>\n\n📋 <b>Detail Transaksi:</b>\n• ID Transaksi: <code>%s</code>\n• Order ID: <code>%s</code>\n• Metode: %s\n• Provider: %s\n• Jumlah: <b>Rp %s</b>\n• Dikreditkan: <b>Rp %s</b>\n• Waktu Settlement: %s",
+		paymentID, transaction.OrderID, getPaymentMethodName(source), provider, formattedAmount, helpers.FormatNumber(transactions.Total, 0), date)
+	wc.sendTelegramAlert(message, "HTML")
+
+	return nil
 }
 
-// HandlePakaiLinkPayoutBank handles webhook callback from PakaiLink for Bank Payout
-// Note: PakaiLink Payout does not send X-SIGNATURE or X-TIMESTAMP headers
-func (wc *WebhookController) HandlePakaiLinkPayoutBank(c *gin.Context) {
-	// Read request body
-	body, err := io.ReadAll(c.Request.Body)
+// creditPayment credits the net amount of a payment to the merchant wallet and writes the ledger legs:
+// the provider owes us the gross amount, the merchant gets the net amount, the rest is fee revenue
+// The wallet row stays locked until the caller's transaction ends, so concurrent credits are serialized
+func (wc *WebhookController) creditPayment(ledgerRepo *repositories.LedgerRepository, walletRepo *repositories.WalletRepository, userID int, paymentID, source, provider string, gross, credit models.Money) error {
+	wallet, err := walletRepo.GetUserWalletForUpdate(userID)
 	if err != nil {
-		c.JSON(http.StatusOK, gin.H{
-			"responseCode":    "2002800",
-			"responseMessage": "Successful",
-		})
-		return
+		return err
 	}
 
-	var requestData map[string]interface{}
-	if err := json.Unmarshal(body, &requestData); err != nil {
-		c.JSON(http.StatusOK, gin.H{
-			"responseCode":    "2002800",
-			"responseMessage": "Successful",
-		})
-		return
+	if err := ledgerRepo.EnsureOpeningBalance(userID, wallet.Balance); err != nil {
+		return err
 	}
 
-	// Extract transactionData
-	transactionData, ok := requestData["transactionData"].(map[string]interface{})
-	if !ok {
-		c.JSON(http.StatusOK, gin.H{
-			"responseCode":    "2002800",
-			"responseMessage": "Successful",
-		})
-		return
+	if err := walletRepo.IncrementWalletBalance(userID, credit); err != nil {
+		return err
 	}
 
-	// Extract data
-	partnerRef, _ := transactionData["partnerReferenceNo"].(string)
-	paymentFlagStatus, _ := transactionData["paymentFlagStatus"].(string)
-	paidAmount, _ := transactionData["paidAmount"].(map[string]interface{})
+	return ledgerRepo.CreateEntries([]models.LedgerEntry{
+		{GrantID: paymentID, UserID: userID, Account: models.LedgerAccountProviderClearing, Direction: models.LedgerDebit, Amount: gross, Description: source + " payment via " + provider},
+		{GrantID: paymentID, UserID: userID, Account: models.LedgerAccountMerchantWallet, Direction: models.LedgerCredit, Amount: credit, Description: source + " payment net of fee"},
+		{GrantID: paymentID, UserID: userID, Account: models.LedgerAccountFeeRevenue, Direction: models.LedgerCredit, Amount: gross - credit, Description: source + " payment fee"},
+	})
+}
 
-	var amount float64
-	if paidAmount != nil {
-		if valueStr, ok := paidAmount["value"].(string); ok {
-			if valueFloat, err := strconv.ParseFloat(valueStr, 64); err == nil {
-				amount = valueFloat
-			}
-		}
+// getPaymentMethodName returns formatted payment method name
+func getPaymentMethodName(source string) string {
+	switch source {
+	case "QRIS":
+		return "QRIS"
+	case "EWALLET":
+		return "E-Wallet"
+	case "VA":
+		return "Virtual Account"
+	default:
+		return source
 	}
+}
 
-	// Map paymentFlagStatus to status
-	status := "PENDING"
-	if paymentFlagStatus == "00" {
-		status = "SUCCESS"
-	} else if paymentFlagStatus != "" {
-		status = "FAILED"
-	}
 
-	// Use current time as date (PakaiLink Payout doesn't send X-TIMESTAMP)
-	loc, err := time.LoadLocation("Asia/Jakarta")
+// sendCallbackToMerchant sends callback to merchant (failed deliveries are retried automatically)
+func (wc *WebhookController) sendCallbackToMerchant(transaction *models.TransactionInfo, payload interface{}) {
+	wc.callbackDelivery.Deliver(transaction, payload)
+}
+
+// sendTelegramAlert sends alert to Telegram
+func (wc *WebhookController) sendTelegramAlert(message, parseMode string) {
+	_ = wc.telegramService.SendMessage(message, parseMode)
+}
+
+// verifyWalletLedger compares the wallet balance with the balance derived from the ledger
+func (wc *WebhookController) verifyWalletLedger(userID int, source, paymentID string) {
+	wallet, err := wc.walletRepo.GetUserWallet(userID)
 	if err != nil {
-		loc = time.UTC
+		return
 	}
-	date := time.Now().In(loc).Format("2006-01-02T15:04:05Z07:00")
 
-	if partnerRef == "" {
-		wc.sendTelegramAlert("⚠️ <b>Callback Error</b>\n\n• Source: Bank Payout PakaiLink\n• Issue: Missing payment ID", "HTML")
-		c.JSON(http.StatusOK, gin.H{
-			"responseCode":    "2002800",
-			"responseMessage": "Successful",
-		})
+	derived, err := wc.ledgerRepo.GetDerivedWalletBalance(userID)
+	if err != nil {
 		return
 	}
 
-	// Process payout transaction
-	wc.processPayoutTransaction(partnerRef, status, amount, date, "Bank", "PakaiLink")
+	if wallet.Balance != derived {
+		wc.sendTelegramAlert(fmt.Sprintf("🚨 <b>Wallet Ledger Mismatch</b>\n\n• Source: %s\n• Payment ID: <code>%s</code>\n• User ID: %d\n• Wallet Balance: Rp %s\n• Ledger Balance: Rp %s", source, paymentID, userID, helpers.FormatNumber(wallet.Balance, 2), helpers.FormatNumber(derived, 2)), "HTML")
+	}
+}
 
-	c.JSON(http.StatusOK, gin.H{
-		"responseCode":    "2002800",
-		"responseMessage": "Successful",
-	})
+// rollbackWithAlert rolls back the settlement transaction, alerts which step failed and returns the wrapped error
+func (wc *WebhookController) rollbackWithAlert(tx *sql.Tx, title, source, paymentID string, err error) error {
+	_ = tx.Rollback()
+	wc.sendTelegramAlert(fmt.Sprintf("❌ <b>%s</b>\n\n• Source: %s\n• Payment ID: <code>%s</code>\n• Error: <code>%s</code>\n• Action: All changes rolled back", title, source, paymentID, err.Error()), "HTML")
+	return fmt.Errorf("%s: %v", strings.ToLower(strings.TrimPrefix(title, "Error ")), err)
 }
 
-// HandlePakaiLinkPayoutEWallet handles webhook callback from PakaiLink for E-Wallet Payout
-// Note: PakaiLink Payout does not send X-SIGNATURE or X-TIMESTAMP headers
-func (wc *WebhookController) HandlePakaiLinkPayoutEWallet(c *gin.Context) {
-	// Read request body
-	body, err := io.ReadAll(c.Request.Body)
-	if err != nil {
-		c.JSON(http.StatusOK, gin.H{
-			"responseCode":    "2002800",
-			"responseMessage": "Successful",
-		})
-		return
+// checkAmount compares the amount of a success callback with the order amount and applies the
+// AMOUNT_MISMATCH_POLICY; returns the amount to settle and the amount_status to record
+func checkAmount(expected, paid models.Money, cfg *config.AmountCheckConfig) (models.Money, string) {
+	difference := paid - expected
+	if difference < 0 {
+		difference = -difference
 	}
-
-	var requestData map[string]interface{}
-	if err := json.Unmarshal(body, &requestData); err != nil {
-		c.JSON(http.StatusOK, gin.H{
-			"responseCode":    "2002800",
-			"responseMessage": "Successful",
-		})
-		return
+	if difference <= cfg.Tolerance {
+		return expected, models.AmountStatusMatched
 	}
 
-	// Extract transactionData
-	transactionData, ok := requestData["transactionData"].(map[string]interface{})
-	if !ok {
-		c.JSON(http.StatusOK, gin.H{
-			"responseCode":    "2002800",
-			"responseMessage": "Successful",
-		})
-		return
+	switch cfg.Policy {
+	case config.AmountPolicyFlag:
+		return expected, models.AmountStatusMismatch
+	case config.AmountPolicyPartial:
+		return paid, models.AmountStatusPartial
+	default:
+		return expected, models.AmountStatusRejected
 	}
+}
 
-	// Extract data
-	partnerRef, _ := transactionData["partnerReferenceNo"].(string)
-	paymentFlagStatus, _ := transactionData["paymentFlagStatus"].(string)
-	paidAmount, _ := transactionData["paidAmount"].(map[string]interface{})
+// sendAmountMismatchAlert alerts a payment callback whose amount differs from the order amount
+func (wc *WebhookController) sendAmountMismatchAlert(source, paymentID string, expected, paid models.Money, amountStatus string) {
+	action := "Callback held for review, nothing settled; requeue with amount_policy flag or partial to accept it"
+	switch amountStatus {
+	case models.AmountStatusMismatch:
+		action = "Settled with the order amount, reconcile manually"
+	case models.AmountStatusPartial:
+		action = "Settled with the paid amount"
+	}
+	wc.sendTelegramAlert(fmt.Sprintf("🚨 <b>Amount Mismatch</b>\n\n• Source: %s\n• Payment ID: <code>%s</code>\n• Order Amount: Rp %s\n• Paid Amount: Rp %s\n• Result: <b>%s</b>\n• Action: %s", source, paymentID, helpers.FormatNumber(expected, 2), helpers.FormatNumber(paid, 2), amountStatus, action), "HTML")
+}
 
-	var amount float64
-	if paidAmount != nil {
-		if valueStr, ok := paidAmount["value"].(string); ok {
-			if valueFloat, err := strconv.ParseFloat(valueStr, 64); err == nil {
-				amount = valueFloat
-			}
-		}
+// rejectTransition rolls back a status change the lifecycle state machine does not allow
+// A repeated status is a duplicate callback; any other transition is alerted and ignored
+func (wc *WebhookController) rejectTransition(tx *sql.Tx, source, paymentID, currentStatus, status string, err error) error {
+	_ = tx.Rollback()
+	if errors.Is(err, models.ErrSameStatus) && strings.EqualFold(currentStatus, models.LifecyclePending) {
+		return errStillPending
+	}
+	if errors.Is(err, models.ErrSameStatus) {
+		wc.sendTelegramAlert(fmt.Sprintf("⚠️ <b>Duplicate Callback Prevented</b>\n\n• Source: %s\n• Payment ID: <code>%s</code>\n• Current Status: <b>%s</b>\n• Attempted Status: %s", source, paymentID, currentStatus, status), "HTML")
+		return errDuplicateCallback
 	}
 
-	// Map paymentFlagStatus to status
-	status := "PENDING"
-	if paymentFlagStatus == "00" {
-		status = "SUCCESS"
-	} else if paymentFlagStatus != "" {
-		status = "FAILED"
+	wc.sendTelegramAlert(fmt.Sprintf("🚫 <b>Rejected Status Transition</b>\n\n• Source: %s\n• Payment ID: <code>%s</code>\n• Current Status: <b>%s</b>\n• Attempted Status: %s\n• Error: <code>%s</code>\n• Action: All changes rolled back", source, paymentID, currentStatus, status, err.Error()), "HTML")
+	return fmt.Errorf("%w: %v", services.ErrEventIgnored, err)
+}
+
+// reverseFunds reverses the ledger position of a grant and applies the merchant_wallet change to the wallet
+// Returns whether the wallet balance changed
+func (wc *WebhookController) reverseFunds(ledgerRepo *repositories.LedgerRepository, walletRepo *repositories.WalletRepository, userID int, balance models.Money, grantID, description string) (bool, error) {
+	if err := ledgerRepo.EnsureOpeningBalance(userID, balance); err != nil {
+		return false, err
 	}
 
-	// Use current time as date (PakaiLink Payout doesn't send X-TIMESTAMP)
-	loc, err := time.LoadLocation("Asia/Jakarta")
-	if err != nil {
-		loc = time.UTC
+	walletChange, err := ledgerRepo.ReverseGrant(grantID, userID, description)
+	if err != nil || walletChange == 0 {
+		return false, err
 	}
-	date := time.Now().In(loc).Format("2006-01-02T15:04:05Z07:00")
 
-	if partnerRef == "" {
-		wc.sendTelegramAlert("⚠️ <b>Callback Error</b>\n\n• Source: E-Wallet Payout PakaiLink\n• Issue: Missing payment ID", "HTML")
-		c.JSON(http.StatusOK, gin.H{
-			"responseCode":    "2002800",
-			"responseMessage": "Successful",
-		})
-		return
+	if err := walletRepo.IncrementWalletBalance(userID, walletChange); err != nil {
+		return false, err
 	}
+	return true, nil
+}
 
-	// Process payout transaction
-	wc.processPayoutTransaction(partnerRef, status, amount, date, "E-Wallet", "PakaiLink")
+// resolveStatus maps a provider status through the status mapping
+// An unmapped (or explicitly "review") status is alerted and returns services.ErrEventHeld
+func (wc *WebhookController) resolveStatus(provider, channel, source, paymentID, status string) (helpers.StatusOutcome, error) {
+	outcome, mapped := helpers.MapStatus(provider, channel, status)
+	if !outcome.Review() {
+		return outcome, nil
+	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"responseCode":    "2002800",
-		"responseMessage": "Successful",
-	})
+	reason := "status is mapped to review"
+	if !mapped {
+		reason = "status is not in the status mapping"
+	}
+	wc.sendTelegramAlert(fmt.Sprintf("⚠️ <b>Callback Held For Review</b>\n\n• Source: %s\n• Channel: %s\n• Payment ID: <code>%s</code>\n• Status: <code>%s</code>\n• Reason: %s\n• Action: Map the status (STATUS_MAPPING_FILE) and requeue the event", source, channel, paymentID, status, reason), "HTML")
+	return outcome, fmt.Errorf("%w: %s status %q: %s", services.ErrEventHeld, provider, status, reason)
 }
 
 // processPayoutTransaction processes the payout transaction update
-func (wc *WebhookController) processPayoutTransaction(paymentID, status string, amount float64, date, paymentMethod, provider string) {
+// All database writes run inside a single transaction; merchant callback and
+// Telegram notification are only sent after the transaction is committed
+func (wc *WebhookController) processPayoutTransaction(ctx context.Context, paymentID, status string, amount models.Money, date, paymentMethod, provider, channel string) error {
+	source := fmt.Sprintf("%s Payout %s", paymentMethod, provider)
+
+	// Map the provider status before touching anything; unmapped statuses are held for review
+	outcome, err := wc.resolveStatus(provider, channel, source, paymentID, status)
+	if err != nil {
+		return err
+	}
+
 	// Get transaction
 	transaction, err := wc.transactionRepo.GetTransactionByGrantID(paymentID)
 	if err != nil {
 		formattedAmount := helpers.FormatNumber(amount, 0)
-		wc.sendTelegramAlert(fmt.Sprintf("ℹ️ <b>Transaction Not Found</b>\n\n• Source: %s Payout %s\n• Payment ID: <code>%s</code>\n• Status: %s\n• Amount: Rp %s\n• Date: %s", paymentMethod, provider, paymentID, status, formattedAmount, date), "HTML")
-		return
+		wc.sendTelegramAlert(fmt.Sprintf("ℹ️ <b>Transaction Not Found</b>\n\n• Source: %s\n• Payment ID: <code>%s</code>\n• Status: %s\n• Amount: Rp %s\n• Date: %s", source, paymentID, status, formattedAmount, date), "HTML")
+		return fmt.Errorf("transaction not found: %v", err)
 	}
 
 	// Get transactions record
 	transactions, err := wc.transactionRepo.GetTransactionsByGrantID(paymentID)
 	if err != nil || transactions == nil {
 		formattedAmount := helpers.FormatNumber(amount, 0)
-		wc.sendTelegramAlert(fmt.Sprintf("ℹ️ <b>Transactions Record Not Found</b>\n\n• Source: %s Payout %s\n• Payment ID: <code>%s</code>\n• Status: %s\n• Amount: Rp %s\n• Date: %s", paymentMethod, provider, paymentID, status, formattedAmount, date), "HTML")
-		return
+		wc.sendTelegramAlert(fmt.Sprintf("ℹ️ <b>Transactions Record Not Found</b>\n\n• Source: %s\n• Payment ID: <code>%s</code>\n• Status: %s\n• Amount: Rp %s\n• Date: %s", source, paymentID, status, formattedAmount, date), "HTML")
+		return fmt.Errorf("transactions record not found")
 	}
 
-	// Get merchant payout
-	merchantPayout, err := wc.merchantRepo.GetMerchantPayoutByGatewayRef(paymentID)
+	// Begin database transaction for the whole payout update
+	tx, err := wc.db.BeginTx(ctx, nil)
+	if err != nil {
+		wc.sendTelegramAlert(fmt.Sprintf("❌ <b>Error Starting Database Transaction</b>\n\n• Source: %s\n• Payment ID: <code>%s</code>\n• Error: <code>%s</code>", source, paymentID, err.Error()), "HTML")
+		return err
+	}
+	// Release the row locks on every early return or panic; a no-op once the transaction is committed
+	defer tx.Rollback()
+	transactionRepo := wc.transactionRepo.WithTx(tx)
+	merchantRepo := wc.merchantRepo.WithTx(tx)
+	walletRepo := wc.walletRepo.WithTx(tx)
+	feesRepo := wc.feesRepo.WithTx(tx)
+	userRepo := wc.userRepo.WithTx(tx)
+	ledgerRepo := wc.ledgerRepo.WithTx(tx)
+
+	// Get merchant payout (row stays locked until commit so concurrent callbacks are serialized)
+	merchantPayout, err := merchantRepo.GetMerchantPayoutByGatewayRefForUpdate(paymentID)
 	if err != nil {
+		_ = tx.Rollback()
 		formattedAmount := helpers.FormatNumber(amount, 0)
-		wc.sendTelegramAlert(fmt.Sprintf("ℹ️ <b>Merchant Payout Not Found</b>\n\n• Source: %s Payout %s\n• Payment ID: <code>%s</code>\n• Status: %s\n• Amount: Rp %s\n• Date: %s", paymentMethod, provider, paymentID, status, formattedAmount, date), "HTML")
-		return
+		wc.sendTelegramAlert(fmt.Sprintf("ℹ️ <b>Merchant Payout Not Found</b>\n\n• Source: %s\n• Payment ID: <code>%s</code>\n• Status: %s\n• Amount: Rp %s\n• Date: %s", source, paymentID, status, formattedAmount, date), "HTML")
+		return fmt.Errorf("merchant payout not found: %v", err)
 	}
-
-	// Check if already processed
-	if merchantPayout.Status != "Pending" {
-		wc.sendTelegramAlert(fmt.Sprintf("⚠️ <b>Duplicate Callback Prevented</b>\n\n• Source: %s Payout %s\n• Payment ID: <code>%s</code>\n• Current Status: <b>%s</b>\n• Attempted Status: %s", paymentMethod, provider, paymentID, merchantPayout.Status, status), "HTML")
-		return
+	if merchantPayout.MerchantID == nil || merchantPayout.PaymentMethodID == nil {
+		return wc.rollbackWithAlert(tx, "Error Incomplete Merchant Payout", source, paymentID, fmt.Errorf("merchant payout has no merchant_id or payment_method_id"))
 	}
 
+	// The requested payout amount is stored and debited; the provider's figure never overwrites it
+	reportedAmount := amount
+	amount = merchantPayout.Amount
+
 	// Normalize status
-	normalizedStatus := helpers.NormalizeStatus(status)
-	normalizedStatus2 := helpers.MerchantNormalizeStatus(status)
+	normalizedStatus := outcome.TransactionStatus()
+	normalizedStatus2 := outcome.MerchantStatus()
+
+	// Check the merchant payout and transactions transitions; a repeated status is a duplicate callback
+	effects, err := models.PayoutLifecycle.Transition(merchantPayout.Status, normalizedStatus2)
+	if err != nil {
+		return wc.rejectTransition(tx, source, paymentID, merchantPayout.Status, status, err)
+	}
+	if _, err := models.TransactionLifecycle.Transition(transactions.Status, normalizedStatus2); err != nil && !errors.Is(err, models.ErrSameStatus) {
+		return wc.rejectTransition(tx, source, paymentID, transactions.Status, status, err)
+	}
 
 	// Update transaction
-	err = wc.transactionRepo.UpdateTransaction(paymentID, normalizedStatus, int64(amount))
+	err = transactionRepo.UpdateTransaction(paymentID, normalizedStatus, transaction.Amount)
 	if err != nil {
-		wc.sendTelegramAlert(fmt.Sprintf("❌ <b>Error Updating Transaction</b>\n\n• Source: %s Payout %s\n• Payment ID: <code>%s</code>\n• Error: <code>%s</code>", paymentMethod, provider, paymentID, err.Error()), "HTML")
-		return
+		return wc.rollbackWithAlert(tx, "Error Updating Transaction", source, paymentID, err)
 	}
 
 	// Update transactions
-	err = wc.transactionRepo.UpdateTransactions(paymentID, normalizedStatus2, normalizedStatus2)
+	err = transactionRepo.UpdateTransactions(paymentID, normalizedStatus2, normalizedStatus2)
 	if err != nil {
-		wc.sendTelegramAlert(fmt.Sprintf("❌ <b>Error Updating Transactions</b>\n\n• Source: %s Payout %s\n• Payment ID: <code>%s</code>\n• Error: <code>%s</code>", paymentMethod, provider, paymentID, err.Error()), "HTML")
-		return
+		return wc.rollbackWithAlert(tx, "Error Updating Transactions", source, paymentID, err)
 	}
 
 	// Update merchant payout
-	err = wc.merchantRepo.UpdateMerchantPayout(paymentID, normalizedStatus2, amount)
+	err = merchantRepo.UpdateMerchantPayout(paymentID, normalizedStatus2, amount)
 	if err != nil {
-		wc.sendTelegramAlert(fmt.Sprintf("❌ <b>Error Updating Merchant Payout</b>\n\n• Source: %s Payout %s\n• Payment ID: <code>%s</code>\n• Error: <code>%s</code>", paymentMethod, provider, paymentID, err.Error()), "HTML")
-		return
+		return wc.rollbackWithAlert(tx, "Error Updating Merchant Payout", source, paymentID, err)
 	}
 
 	// Get merchant and user
-	merchant, err := wc.merchantRepo.GetMerchantByID(*merchantPayout.MerchantID)
+	merchant, err := merchantRepo.GetMerchantByID(*merchantPayout.MerchantID)
 	if err != nil {
-		wc.sendTelegramAlert(fmt.Sprintf("❌ <b>Error Getting Merchant</b>\n\n• Source: %s Payout %s\n• Payment ID: <code>%s</code>\n• Error: <code>%s</code>", paymentMethod, provider, paymentID, err.Error()), "HTML")
-		return
+		return wc.rollbackWithAlert(tx, "Error Getting Merchant", source, paymentID, err)
 	}
 
-	// Get user (need to add GetUserByID to repository)
+	// Lock wallet row so balance changes from other callbacks wait for this transaction
 	userID := merchant.UserID
-	wallet, err := wc.walletRepo.GetUserWallet(userID)
+	wallet, err := walletRepo.GetUserWalletForUpdate(userID)
 	if err != nil {
-		wc.sendTelegramAlert(fmt.Sprintf("❌ <b>Error Getting Wallet</b>\n\n• Source: %s Payout %s\n• Payment ID: <code>%s</code>\n• Error: <code>%s</code>", paymentMethod, provider, paymentID, err.Error()), "HTML")
-		return
+		return wc.rollbackWithAlert(tx, "Error Getting Wallet", source, paymentID, err)
 	}
 
 	// Get fees
-	feeReguler, _ := wc.feesRepo.GetFeesLimit(9, *merchantPayout.PaymentMethodID)
-	feeExpress, _ := wc.feesRepo.GetFeesExpress(9)
+	feeReguler, _ := feesRepo.GetFeesLimit(9, *merchantPayout.PaymentMethodID)
+	feeExpress, _ := feesRepo.GetFeesExpress(9)
 
-	// Calculate charges
-	var chargePercentage, chargeFixed float64
+	// Calculate charges (percentage fee is rounded half away from zero to the cent)
+	var chargePercentage models.Rate
+	var chargeFixed models.Money
 	if feeReguler != nil && feeExpress != nil {
 		chargePercentage = feeReguler.ChargePercentage
 		chargeFixed = feeReguler.ChargeFixed
 	} else {
-		chargePercentage = 1.5
-		chargeFixed = 5000.0
+		chargePercentage = models.MustParseRate("1.5")
+		chargeFixed = models.Rupiah(5000)
 	}
 
-	chargePercentageAmount := (amount * chargePercentage) / 100
+	chargePercentageAmount := amount.Percent(chargePercentage)
 
 	// Get user to check role_id
-	user, err := wc.userRepo.GetUserByID(userID)
+	user, err := userRepo.GetUserByID(userID)
 	if err != nil {
-		wc.sendTelegramAlert(fmt.Sprintf("❌ <b>Error Getting User</b>\n\n• Source: %s Payout %s\n• Payment ID: <code>%s</code>\n• Error: <code>%s</code>", paymentMethod, provider, paymentID, err.Error()), "HTML")
-		return
+		return wc.rollbackWithAlert(tx, "Error Getting User", source, paymentID, err)
 	}
 
 	// Calculate total fee based on role_id
-	var finalTotalFee float64
+	var finalTotalFee models.Money
 	if user.RoleID != nil && *user.RoleID == 3 {
 		// Role ID 3 = reguler fee
 		finalTotalFee = chargePercentageAmount + chargeFixed
 	} else {
 		// Other roles = express fee
-		var chargePercentageExpress, chargeFixedExpress float64
+		var chargePercentageExpress models.Rate
+		var chargeFixedExpress models.Money
 		if feeExpress != nil {
 			chargePercentageExpress = feeExpress.ChargePercentage
 			chargeFixedExpress = feeExpress.ChargeFixed
 		} else {
-			chargePercentageExpress = 1.5
-			chargeFixedExpress = 7000.0
+			chargePercentageExpress = models.MustParseRate("1.5")
+			chargeFixedExpress = models.Rupiah(7000)
 		}
-		finalTotalFee = (amount*chargePercentageExpress)/100 + chargeFixedExpress
+		finalTotalFee = amount.Percent(chargePercentageExpress) + chargeFixedExpress
 	}
 
-	// Update wallet balance if status is Success (deduct amount + fee)
-	if normalizedStatus2 == "Success" {
-		newBalance := wallet.Balance - (amount + finalTotalFee)
-		err = wc.walletRepo.UpdateWalletBalance(userID, newBalance)
+	// Update wallet balance when the payout succeeds (deduct amount + fee)
+	walletMoved := false
+	if models.HasEffect(effects, models.EffectMoveFunds) {
+		err = ledgerRepo.EnsureOpeningBalance(userID, wallet.Balance)
 		if err != nil {
-			wc.sendTelegramAlert(fmt.Sprintf("❌ <b>Error Updating Wallet</b>\n\n• Source: %s Payout %s\n• Payment ID: <code>%s</code>\n• Error: <code>%s</code>", paymentMethod, provider, paymentID, err.Error()), "HTML")
-			return
+			return wc.rollbackWithAlert(tx, "Error Writing Ledger", source, paymentID, err)
 		}
+
+		err = walletRepo.IncrementWalletBalance(userID, -(amount + finalTotalFee))
+		if err != nil {
+			return wc.rollbackWithAlert(tx, "Error Updating Wallet", source, paymentID, err)
+		}
+
+		// Merchant pays amount + fee, provider is owed the amount, the rest is fee revenue
+		err = ledgerRepo.CreateEntries([]models.LedgerEntry{
+			{GrantID: paymentID, UserID: userID, Account: models.LedgerAccountMerchantWallet, Direction: models.LedgerDebit, Amount: amount + finalTotalFee, Description: source + " incl. fee"},
+			{GrantID: paymentID, UserID: userID, Account: models.LedgerAccountProviderClearing, Direction: models.LedgerCredit, Amount: amount, Description: source},
+			{GrantID: paymentID, UserID: userID, Account: models.LedgerAccountFeeRevenue, Direction: models.LedgerCredit, Amount: finalTotalFee, Description: source + " fee"},
+		})
+		if err != nil {
+			return wc.rollbackWithAlert(tx, "Error Writing Ledger", source, paymentID, err)
+		}
+		walletMoved = true
+	}
+
+	// Returned payout: give back amount + fee debited when it succeeded
+	if models.HasEffect(effects, models.EffectReverseFunds) {
+		moved, err := wc.reverseFunds(ledgerRepo, walletRepo, userID, wallet.Balance, paymentID, source+" returned")
+		if err != nil {
+			return wc.rollbackWithAlert(tx, "Error Reversing Funds", source, paymentID, err)
+		}
+		walletMoved = walletMoved || moved
+	}
+
+	// Commit all changes at once
+	if err := tx.Commit(); err != nil {
+		return wc.rollbackWithAlert(tx, "Error Committing Transaction", source, paymentID, err)
+	}
+
+	if walletMoved {
+		wc.verifyWalletLedger(userID, source, paymentID)
+	}
+
+	if reportedAmount != 0 && reportedAmount != amount {
+		wc.sendAmountMismatchAlert(source, paymentID, amount, reportedAmount, models.AmountStatusMismatch)
 	}
 
 	// Send callback to merchant (V2 format only)
-	payloads := services.BuildPayloadV2Payout(transaction, paymentID, normalizedStatus2, date)
-	payload := payloads["PAYOUTS"]
-	wc.sendCallbackToMerchant(transaction, payload)
+	if models.HasEffect(effects, models.EffectNotifyMerchant) {
+		payloads := services.BuildPayloadV2Payout(transaction, paymentID, normalizedStatus2, date)
+		payload := payloads["PAYOUTS"]
+		wc.sendCallbackToMerchant(transaction, payload)
+	}
 
 	// Send Telegram notification
 	formattedAmount := helpers.FormatNumber(amount, 0)
 	message := fmt.Sprintf("✅ <b>Payout Status Updated</b>\n\n📋 <b>Detail Transaksi:</b>\n• ID Transaksi: <code>%s</code>\n• Order ID: <code>%s</code>\n• Metode: %s\n• Provider: %s\n• Jumlah: <b>Rp %s</b>\n• Status: <b>%s</b>\n• Waktu: %s",
 		paymentID, transaction.OrderID, paymentMethod, provider, formattedAmount, normalizedStatus2, date)
 	wc.sendTelegramAlert(message, "HTML")
-}
 
+	return nil
+}
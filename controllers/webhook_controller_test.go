@@ -0,0 +1,302 @@
+package controllers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/kytapay/webhook-v2/config"
+	"github.com/kytapay/webhook-v2/helpers"
+	"github.com/kytapay/webhook-v2/models"
+	"github.com/kytapay/webhook-v2/providers"
+	"github.com/kytapay/webhook-v2/repositories"
+	"github.com/kytapay/webhook-v2/services"
+)
+
+// TestUnknownPakaiLinkFlagIsHeld checks that a paymentFlagStatus missing from the status mapping
+// holds the event before any database access, for payments and payouts
+func TestUnknownPakaiLinkFlagIsHeld(t *testing.T) {
+	wc := &WebhookController{telegramService: services.NewTelegramService()}
+
+	err := wc.processPayoutTransaction(context.Background(), "KP-PO-20261018-0103", "07", models.Rupiah(150000), "2026-10-18T13:06:10+07:00", "Bank", "PakaiLink", "PAYOUT_BANK")
+	if !errors.Is(err, services.ErrEventHeld) {
+		t.Errorf("payout flag 07: expected ErrEventHeld, got %v", err)
+	}
+
+	err = wc.processTransaction(context.Background(), "KP-VA-20261018-0101", "06", models.Rupiah(75000), "2026-10-18T10:26:13+07:00", "VA", "PakaiLink", "VA", "")
+	if !errors.Is(err, services.ErrEventHeld) {
+		t.Errorf("VA flag 06: expected ErrEventHeld, got %v", err)
+	}
+}
+
+// callbackStep is one queued callback of a payment: a payment callback with its mapped status,
+// or a settlement callback
+type callbackStep struct {
+	outcome    helpers.StatusOutcome
+	settlement bool
+}
+
+var settlementStep = callbackStep{settlement: true}
+
+// TestWalletCreditedOnce replays callback sequences through planPayment and planSettlement, applying
+// the statuses processTransaction and processSettlement write, and counts the wallet credits
+func TestWalletCreditedOnce(t *testing.T) {
+	success := callbackStep{outcome: config.StatusOutcomeSuccess}
+	captured := callbackStep{outcome: config.StatusOutcomeCaptured}
+	pending := callbackStep{outcome: config.StatusOutcomePending}
+	failed := callbackStep{outcome: config.StatusOutcomeFailed}
+
+	tests := []struct {
+		name            string
+		source          string
+		paymentMethodID int
+		steps           []callbackStep
+		credits         int
+	}{
+		{"VA credited at payment", "VA", 1, []callbackStep{pending, success, success, settlementStep, settlementStep}, 1},
+		{"VA late success after expiry", "VA", 1, []callbackStep{failed, success, success, settlementStep}, 1},
+		{"QRIS credited at settlement", "QRIS", 11, []callbackStep{success, success, settlementStep, settlementStep}, 1},
+		{"settlement overtakes payment", "QRIS", 11, []callbackStep{settlementStep, success, settlementStep}, 1},
+		{"QRIS late success after failure", "QRIS", 11, []callbackStep{failed, success, settlementStep}, 1},
+		{"failed payment", "QRIS", 11, []callbackStep{pending, failed, failed, settlementStep}, 0},
+		{"card capture then settlement", "CREDIT_CARD", 30, []callbackStep{pending, captured, captured, settlementStep, settlementStep}, 1},
+		{"card settlement overtakes capture", "CREDIT_CARD", 30, []callbackStep{settlementStep, captured, settlementStep}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			paymentMethodID := tt.paymentMethodID
+			paymentStatus, transactionsStatus := models.LifecyclePending, ""
+			credits := 0
+
+			for _, step := range tt.steps {
+				if step.settlement {
+					creditWallet, err := planSettlement(paymentStatus, transactionsStatus, tt.source, &paymentMethodID)
+					if err != nil {
+						continue
+					}
+					transactionsStatus = models.LifecycleSuccess
+					if creditWallet {
+						credits++
+					}
+					continue
+				}
+
+				plan, _, err := planPayment(paymentStatus, transactionsStatus, step.outcome, tt.source, &paymentMethodID)
+				if err != nil {
+					continue
+				}
+				paymentStatus, transactionsStatus = step.outcome.MerchantStatus(), plan.transactionsStatus
+				if plan.creditWallet {
+					credits++
+				}
+			}
+
+			if credits != tt.credits {
+				t.Errorf("wallet credited %d times, want %d", credits, tt.credits)
+			}
+		})
+	}
+}
+
+// TestMidtransCaptureThenSettlement runs the capture and settlement notifications of one card order
+// through the Midtrans adapter, the status mapping and the plans, and expects a single wallet credit
+func TestMidtransCaptureThenSettlement(t *testing.T) {
+	provider := providers.NewMidtrans()
+	paymentMethodID := 30
+	paymentStatus, transactionsStatus := models.LifecyclePending, ""
+	credits := 0
+
+	for _, fixture := range []string{"card_capture.json", "card_capture.json", "card_settlement.json", "card_settlement.json"} {
+		body, err := os.ReadFile(filepath.Join("..", "providers", "testdata", "midtrans", fixture))
+		if err != nil {
+			t.Fatal(err)
+		}
+		event, err := provider.Parse(&providers.Request{Method: "POST", Body: body})
+		if err != nil {
+			t.Fatal(err)
+		}
+		payment := event.Payment
+
+		if payment.Settlement {
+			creditWallet, err := planSettlement(paymentStatus, transactionsStatus, payment.Method, &paymentMethodID)
+			if err != nil {
+				continue
+			}
+			transactionsStatus = models.LifecycleSuccess
+			if creditWallet {
+				credits++
+			}
+			continue
+		}
+
+		outcome, _ := helpers.MapStatus(provider.Name(), provider.Channel().Code, payment.Status)
+		plan, _, err := planPayment(paymentStatus, transactionsStatus, outcome, payment.Method, &paymentMethodID)
+		if err != nil {
+			continue
+		}
+		paymentStatus, transactionsStatus = outcome.MerchantStatus(), plan.transactionsStatus
+		if plan.creditWallet {
+			credits++
+		}
+	}
+
+	if paymentStatus != models.LifecycleSuccess || transactionsStatus != models.LifecycleSuccess {
+		t.Errorf("merchant payment %s, transactions %s, want both Success", paymentStatus, transactionsStatus)
+	}
+	if credits != 1 {
+		t.Errorf("wallet credited %d times, want 1", credits)
+	}
+}
+
+func TestPlanRejections(t *testing.T) {
+	qris := 11
+
+	_, from, err := planPayment(models.LifecycleSuccess, models.LifecyclePendingSettlement, config.StatusOutcomeSuccess, "QRIS", &qris)
+	if !errors.Is(err, models.ErrSameStatus) || from != models.LifecycleSuccess {
+		t.Errorf("duplicate payment: got %q, %v", from, err)
+	}
+
+	_, from, err = planPayment(models.LifecycleRefunded, models.LifecycleRefunded, config.StatusOutcomeSuccess, "QRIS", &qris)
+	if !errors.Is(err, models.ErrTransitionNotAllowed) || from != models.LifecycleRefunded {
+		t.Errorf("success after refund: got %q, %v", from, err)
+	}
+
+	if _, err := planSettlement(models.LifecyclePending, "", "QRIS", &qris); !errors.Is(err, errSettlementBeforePayment) {
+		t.Errorf("settlement before payment: got %v", err)
+	}
+
+	if _, err := planSettlement(models.LifecycleSuccess, models.LifecycleSuccess, "QRIS", &qris); !errors.Is(err, models.ErrSameStatus) {
+		t.Errorf("duplicate settlement: got %v", err)
+	}
+}
+
+// testDB opens the scratch MySQL database from WEBHOOK_TEST_MYSQL_DSN
+// (e.g. user:pass@tcp(localhost:3306)/webhook_test?parseTime=true) and creates the wallet and ledger tables
+func testDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	dsn := os.Getenv("WEBHOOK_TEST_MYSQL_DSN")
+	if dsn == "" {
+		t.Skip("WEBHOOK_TEST_MYSQL_DSN not set")
+	}
+
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(32)
+	t.Cleanup(func() { db.Close() })
+
+	migration, err := os.ReadFile("../migrations/001_create_ledger_entries.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := []string{
+		`CREATE TABLE IF NOT EXISTS wallets (
+			id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+			user_id INT UNSIGNED NOT NULL,
+			balance DECIMAL(20, 2) NOT NULL DEFAULT 0,
+			created_at TIMESTAMP NULL DEFAULT NULL,
+			updated_at TIMESTAMP NULL DEFAULT NULL,
+			PRIMARY KEY (id),
+			UNIQUE KEY wallets_user_id_unique (user_id)
+		) ENGINE=InnoDB`,
+		string(migration),
+	}
+	for _, query := range schema {
+		if _, err := db.Exec(query); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return db
+}
+
+// TestConcurrentWalletCredits fires many simultaneous QRIS / VA style credits for one user through
+// creditPayment, each in its own transaction, and checks that no update is lost
+func TestConcurrentWalletCredits(t *testing.T) {
+	db := testDB(t)
+	wc := &WebhookController{
+		db:         db,
+		walletRepo: repositories.NewWalletRepository(db),
+		ledgerRepo: repositories.NewLedgerRepository(db),
+	}
+
+	userID := int(time.Now().UnixNano() % 1000000000)
+	opening := models.Rupiah(50000)
+	if _, err := db.Exec(`INSERT INTO wallets (user_id, balance, created_at, updated_at) VALUES (?, ?, NOW(), NOW())`, userID, opening); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Exec(`DELETE FROM wallets WHERE user_id = ?`, userID)
+		db.Exec(`DELETE FROM ledger_entries WHERE user_id = ?`, userID)
+	})
+
+	const callbacks = 50
+	var wg sync.WaitGroup
+	errs := make(chan error, callbacks)
+	var expected models.Money
+	for i := 0; i < callbacks; i++ {
+		gross := models.Rupiah(int64(10000 + i*1000))
+		fee := gross.Percent(models.MustParseRate("0.7")) + models.Rupiah(500)
+		expected += gross - fee
+
+		source := "QRIS"
+		if i%2 == 1 {
+			source = "VA"
+		}
+		paymentID := fmt.Sprintf("TEST-%d-%d", userID, i)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- creditInTx(wc, userID, paymentID, source, gross, gross-fee)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	wallet, err := wc.walletRepo.GetUserWallet(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := opening + expected; wallet.Balance != want {
+		t.Errorf("wallet balance = %s, want %s", wallet.Balance, want)
+	}
+
+	derived, err := wc.ledgerRepo.GetDerivedWalletBalance(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if derived != wallet.Balance {
+		t.Errorf("ledger balance = %s, wallet balance = %s", derived, wallet.Balance)
+	}
+}
+
+// creditInTx runs creditPayment in its own transaction, as processTransaction and processSettlement do
+func creditInTx(wc *WebhookController, userID int, paymentID, source string, gross, credit models.Money) error {
+	tx, err := wc.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := wc.creditPayment(wc.ledgerRepo.WithTx(tx), wc.walletRepo.WithTx(tx), userID, paymentID, source, "Test", gross, credit); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,159 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kytapay/webhook-v2/config"
+	"github.com/kytapay/webhook-v2/helpers"
+	"github.com/kytapay/webhook-v2/models"
+	"github.com/kytapay/webhook-v2/providers"
+	"github.com/kytapay/webhook-v2/services"
+)
+
+// errDuplicateCallback is returned when a callback targets an already processed payment/payout
+var errDuplicateCallback = fmt.Errorf("callback already processed: %w", services.ErrEventIgnored)
+
+// errStillPending is returned for a pending callback on a payment/payout that is still pending
+// (e.g. Midtrans notifies pending for every new order), which needs no alert
+var errStillPending = fmt.Errorf("status unchanged, still pending: %w", services.ErrEventIgnored)
+
+// sensitiveHeaders are masked before headers are stored in webhook_events
+var sensitiveHeaders = map[string]bool{
+	"client-secret":    true,
+	"authorization":    true,
+	"x-callback-token": true,
+	"cookie":           true,
+}
+
+// receiveEvent reads the raw callback and stores it in webhook_events before any processing
+// When the event cannot be stored an alert is sent and an error returned, so the provider retries later
+func (wc *WebhookController) receiveEvent(c *gin.Context, provider, channel string) (*models.WebhookEvent, []byte, error) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	headers := make(map[string]string, len(c.Request.Header))
+	for name, values := range c.Request.Header {
+		value := strings.Join(values, ", ")
+		if sensitiveHeaders[strings.ToLower(name)] || strings.EqualFold(name, config.GetPakaiLinkConfig().CallbackTokenHeader) {
+			value = "***"
+		}
+		headers[name] = value
+	}
+	headersJSON, _ := json.Marshal(headers)
+
+	event := &models.WebhookEvent{
+		Provider:           provider,
+		Channel:            channel,
+		Route:              c.FullPath(),
+		SourceIP:           c.ClientIP(),
+		Headers:            string(headersJSON),
+		Body:               string(body),
+		VerificationStatus: models.WebhookVerificationPending,
+		ProcessingStatus:   models.WebhookStatusReceived,
+	}
+
+	if err := wc.webhookEventRepo.CreateEvent(event); err != nil {
+		wc.sendTelegramAlert(fmt.Sprintf("❌ <b>Error Storing Webhook Event</b>\n\n• Source: %s %s\n• Route: <code>%s</code>\n• Error: <code>%s</code>", channel, provider, event.Route, err.Error()), "HTML")
+		return nil, nil, err
+	}
+
+	return event, body, nil
+}
+
+// markEventVerification records the verification result of a stored event
+func (wc *WebhookController) markEventVerification(event *models.WebhookEvent, verificationStatus string) {
+	event.VerificationStatus = verificationStatus
+	_ = wc.webhookEventRepo.UpdateVerification(event.ID, verificationStatus)
+}
+
+// checkReplay remembers the replay key (signature) of a verified callback
+// Returns helpers.ErrReplayDetected when the provider reference + key was already accepted
+func (wc *WebhookController) checkReplay(event *models.WebhookEvent, partnerRef, replayKey string) error {
+	if replayKey == "" {
+		return nil
+	}
+	return helpers.CheckReplay(wc.webhookNonceRepo, event.Provider, partnerRef, replayKey)
+}
+
+// releaseReplay forgets the replay key of a callback that could not be queued, so the provider's retry is accepted
+func (wc *WebhookController) releaseReplay(event *models.WebhookEvent, partnerRef, replayKey string) {
+	if replayKey != "" {
+		_ = wc.webhookNonceRepo.Forget(event.Provider, partnerRef, replayKey)
+	}
+}
+
+// checkExternalID claims the external id of a verified request for its day
+// Returns helpers.ErrDuplicateExternalID when the provider already used it
+func (wc *WebhookController) checkExternalID(event *models.WebhookEvent, externalID *providers.ExternalID) error {
+	if externalID == nil {
+		return nil
+	}
+	return helpers.CheckExternalID(wc.snapExternalIDRepo, event.Provider, externalID.Day, externalID.ID)
+}
+
+// releaseExternalID frees the external id of a request that was not accepted, so the provider can retry with it
+func (wc *WebhookController) releaseExternalID(event *models.WebhookEvent, externalID *providers.ExternalID) {
+	if externalID != nil {
+		_ = wc.snapExternalIDRepo.Release(event.Provider, externalID.Day, externalID.ID)
+	}
+}
+
+// finishEvent records the processing outcome of a stored event
+func (wc *WebhookController) finishEvent(event *models.WebhookEvent, partnerRef, processingStatus, message string) {
+	event.ProcessingStatus = processingStatus
+	_ = wc.webhookEventRepo.UpdateOutcome(event.ID, partnerRef, processingStatus, message)
+}
+
+// queueEvent stores the parsed callback fields and wakes the webhook worker
+// The provider can be acknowledged as soon as this returns without error
+func (wc *WebhookController) queueEvent(event *models.WebhookEvent, eventType, partnerRef, status string, amount models.Money, eventTime, method string) error {
+	event.PartnerReference = &partnerRef
+	event.EventType = &eventType
+	event.EventStatus = &status
+	event.Amount = amount
+	event.EventTime = &eventTime
+	event.Method = &method
+
+	if err := wc.webhookEventRepo.QueueEvent(event); err != nil {
+		wc.sendTelegramAlert(fmt.Sprintf("❌ <b>Error Queueing Webhook Event</b>\n\n• Source: %s %s\n• Payment ID: <code>%s</code>\n• Error: <code>%s</code>", event.Channel, event.Provider, partnerRef, err.Error()), "HTML")
+		return err
+	}
+
+	wc.eventWorker.Notify()
+	return nil
+}
+
+// ProcessEvent runs the settlement pipeline for a queued event, called by the webhook worker
+// The database transaction is bound to ctx, so it is rolled back when the processing timeout passes
+func (wc *WebhookController) ProcessEvent(ctx context.Context, event *models.WebhookEvent) error {
+	partnerRef := stringValue(event.PartnerReference)
+	status := stringValue(event.EventStatus)
+	eventTime := stringValue(event.EventTime)
+	method := stringValue(event.Method)
+
+	switch stringValue(event.EventType) {
+	case models.WebhookEventPayment:
+		return wc.processTransaction(ctx, partnerRef, status, event.Amount, eventTime, method, event.Provider, event.Channel, stringValue(event.AmountPolicy))
+	case models.WebhookEventPayout:
+		return wc.processPayoutTransaction(ctx, partnerRef, status, event.Amount, eventTime, method, event.Provider, event.Channel)
+	case models.WebhookEventSettlement:
+		return wc.processSettlement(ctx, partnerRef, event.Amount, eventTime, method, event.Provider)
+	default:
+		return fmt.Errorf("unknown event type %q: %w", stringValue(event.EventType), services.ErrEventIgnored)
+	}
+}
+
+// stringValue dereferences an optional string
+func stringValue(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
@@ -0,0 +1,34 @@
+package helpers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/kytapay/webhook-v2/models"
+)
+
+// DecodeJSON decodes a callback body keeping numbers as json.Number,
+// so amounts are never rounded through float64
+func DecodeJSON(body []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+// ParseAmount converts an amount field from a decoded callback (number or string) to Money
+// A missing field is zero; a malformed amount is an error, never silently zero
+func ParseAmount(value interface{}) (models.Money, error) {
+	switch v := value.(type) {
+	case nil:
+		return 0, nil
+	case json.Number:
+		return models.ParseMoney(v.String())
+	case string:
+		return models.ParseMoney(v)
+	case float64:
+		return models.MoneyFromFloat(v), nil
+	default:
+		return 0, fmt.Errorf("invalid money amount of type %T", value)
+	}
+}
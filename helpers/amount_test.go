@@ -0,0 +1,46 @@
+package helpers
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/kytapay/webhook-v2/models"
+)
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		want  models.Money
+		err   bool
+	}{
+		{name: "missing", value: nil, want: 0},
+		{name: "JSON number", value: json.Number("75000"), want: models.Rupiah(75000)},
+		{name: "JSON number with cents", value: json.Number("80000.50"), want: models.Rupiah(80000) + 50},
+		{name: "string", value: "10000.00", want: models.Rupiah(10000)},
+		{name: "float", value: float64(125000), want: models.Rupiah(125000)},
+		{name: "malformed string", value: "10,000", err: true},
+		{name: "empty string", value: "", err: true},
+		{name: "malformed number", value: json.Number("1e"), err: true},
+		{name: "object", value: map[string]interface{}{"value": "10000"}, err: true},
+		{name: "bool", value: true, err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseAmount(tt.value)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected an error, got %s", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
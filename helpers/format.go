@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+
+	"github.com/kytapay/webhook-v2/models"
 )
 
 // FormatNumber formats number with thousand separators (using dot)
@@ -16,6 +18,8 @@ func FormatNumber(num interface{}, decimals int) string {
 		numFloat = float64(v)
 	case int64:
 		numFloat = float64(v)
+	case models.Money:
+		numFloat = v.Float64()
 	case string:
 		var err error
 		numFloat, err = strconv.ParseFloat(v, 64)
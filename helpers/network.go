@@ -0,0 +1,84 @@
+package helpers
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ipAlertInterval limits rejection alerts to one per source IP per interval
+const ipAlertInterval = 10 * time.Minute
+
+// lastIPAlerts holds the time of the last rejection alert per provider and source IP
+var lastIPAlerts sync.Map
+
+// ParseIPAllowlist parses allowlist entries given as single IPs or CIDR ranges
+// Invalid entries are skipped and reported in the returned error
+func ParseIPAllowlist(entries []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(entries))
+	var invalid []string
+
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			networks = append(networks, network)
+			continue
+		}
+
+		ip := net.ParseIP(entry)
+		if ip == nil {
+			invalid = append(invalid, entry)
+			continue
+		}
+		bits := 128
+		if ip.To4() != nil {
+			ip = ip.To4()
+			bits = 32
+		}
+		networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+	}
+
+	if len(invalid) > 0 {
+		return networks, fmt.Errorf("invalid IP allowlist entries: %s", strings.Join(invalid, ", "))
+	}
+	return networks, nil
+}
+
+// IPInNetworks reports whether ip belongs to one of the networks
+func IPInNetworks(ip string, networks []*net.IPNet) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+
+	for _, network := range networks {
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// IPAllowed reports whether ip is in the allowlist (IPs or CIDR ranges)
+// An empty allowlist allows every address; a list with only invalid entries allows none
+func IPAllowed(ip string, entries []string) bool {
+	if len(entries) == 0 {
+		return true
+	}
+	networks, _ := ParseIPAllowlist(entries)
+	return IPInNetworks(ip, networks)
+}
+
+// ShouldAlertRejectedIP reports whether a rejection of ip for provider should be alerted,
+// at most once per source IP per 10 minutes
+func ShouldAlertRejectedIP(provider, ip string) bool {
+	key := provider + "|" + ip
+	now := time.Now()
+	if last, ok := lastIPAlerts.Load(key); ok && now.Sub(last.(time.Time)) < ipAlertInterval {
+		return false
+	}
+	lastIPAlerts.Store(key, now)
+	return true
+}
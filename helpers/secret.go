@@ -0,0 +1,51 @@
+package helpers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// secretHashScheme prefixes hashed secrets: sha256:<salt hex>:<hex(SHA-256(salt + secret))>
+// ":" is used as separator because godotenv expands "$" in .env values
+const secretHashScheme = "sha256"
+
+// SecureCompare compares two secrets in constant time
+// Lengths still leak, which is acceptable for fixed-length credentials and signatures
+func SecureCompare(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// HashSecret returns a salted hash of secret suitable for env configuration (e.g. LINKQU_CLIENT_SECRET_HASH)
+func HashSecret(secret string) (string, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s:%s:%s", secretHashScheme, hex.EncodeToString(salt), hashWithSalt(salt, secret)), nil
+}
+
+// VerifySecretHash checks secret against a value produced by HashSecret in constant time
+func VerifySecretHash(secret, encoded string) bool {
+	parts := strings.Split(strings.TrimSpace(encoded), ":")
+	if len(parts) != 3 || parts[0] != secretHashScheme {
+		return false
+	}
+
+	salt, err := hex.DecodeString(parts[1])
+	if err != nil {
+		return false
+	}
+	return SecureCompare(hashWithSalt(salt, secret), strings.ToLower(parts[2]))
+}
+
+// hashWithSalt returns hex(SHA-256(salt + secret))
+func hashWithSalt(salt []byte, secret string) string {
+	hash := sha256.New()
+	hash.Write(salt)
+	hash.Write([]byte(secret))
+	return hex.EncodeToString(hash.Sum(nil))
+}
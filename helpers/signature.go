@@ -1,158 +1,207 @@
 package helpers
 
 import (
-	"crypto"
-	"crypto/hmac"
-	"crypto/rsa"
-	"crypto/sha256"
 	"crypto/sha512"
-	"encoding/base64"
 	"encoding/hex"
-	"encoding/json"
+	"errors"
+	"expvar"
 	"fmt"
+	"net/http"
 	"strings"
+	"time"
 
 	"github.com/kytapay/webhook-v2/config"
+	"github.com/kytapay/webhook-v2/pkg/snap"
 )
 
+var (
+	// ErrTimestampSkew is returned when X-TIMESTAMP is too far from the server clock
+	ErrTimestampSkew = errors.New("X-TIMESTAMP outside allowed skew")
+	// ErrReplayDetected is returned when a provider reference + signature pair was already accepted
+	ErrReplayDetected = errors.New("callback replay detected")
+)
+
+// ReplayMetrics counts replay protection outcomes per provider, published under /admin/metrics
+// Keys: <provider>.checked, <provider>.replayed, <provider>.timestamp_skew
+var ReplayMetrics = expvar.NewMap("webhook_replay")
+
+// NonceStore remembers accepted provider reference + signature pairs (see repositories.WebhookNonceRepository)
+type NonceStore interface {
+	Remember(provider, reference, signature string, expiresAt time.Time) (bool, error)
+}
+
 // VerifyLinkQuSignature verifies LinkQu signature from callback
 // LinkQu menggunakan client-id dan client-secret di header untuk validasi
+// Semua credential aktif (current + next) dicoba, sehingga rotasi tidak memutus callback
 func VerifyLinkQuSignature(clientID, clientSecret string) bool {
-	linkQuConfig := config.GetLinkQuConfig()
-	return clientID == linkQuConfig.ClientID && clientSecret == linkQuConfig.ClientSecret
+	matched := false
+	for _, credential := range config.GetLinkQuConfig().ActiveCredentials(time.Now()) {
+		// Evaluate every check so the response time does not reveal which one failed
+		idMatch := SecureCompare(clientID, credential.ClientID)
+		var secretMatch bool
+		if credential.ClientSecretHash != "" {
+			secretMatch = VerifySecretHash(clientSecret, credential.ClientSecretHash)
+		} else {
+			secretMatch = SecureCompare(clientSecret, credential.ClientSecret)
+		}
+		if idMatch && secretMatch {
+			matched = true
+		}
+	}
+	return matched
 }
 
-// VerifyPakaiLinkSignature verifies PakaiLink signature for webhook callback
-// Supports both symmetric (HMAC SHA-512) and asymmetric (RSA SHA-256) signatures
-// Format: <HTTP METHOD> + ":" + <PATH URL CALLBACK> + ":" + LowerCase(HexEncode(SHA-256(Minify(<HTTP BODY>)))) + ":" + <X-TIMESTAMP>
-func VerifyPakaiLinkSignature(method, path, body, timestamp, signature string) bool {
-	pakaiLinkConfig := config.GetPakaiLinkConfig()
-
-	// Minify request body
-	var bodyMap map[string]interface{}
-	if err := json.Unmarshal([]byte(body), &bodyMap); err != nil {
+// VerifyXenditCallbackToken checks the x-callback-token header against the active Xendit tokens
+func VerifyXenditCallbackToken(token string) bool {
+	if token == "" {
 		return false
 	}
 
-	minifiedBody, err := json.Marshal(bodyMap)
-	if err != nil {
-		return false
+	matched := false
+	for _, credential := range config.GetXenditConfig().ActiveCredentials(time.Now()) {
+		if SecureCompare(token, credential.CallbackToken) {
+			matched = true
+		}
 	}
+	return matched
+}
 
-	// SHA-256 hash of minified body
-	hash := sha256.Sum256(minifiedBody)
-	hashHex := strings.ToLower(hex.EncodeToString(hash[:]))
-
-	// Compose string to sign: METHOD:PATH:HASH:TIMESTAMP
-	stringToSign := fmt.Sprintf("%s:%s:%s:%s", method, path, hashHex, timestamp)
-
-	// Try RSA verification first if public key is available
-	if pakaiLinkConfig.RSAPublicKey != nil {
-		return verifyRSASignature(stringToSign, signature, pakaiLinkConfig.RSAPublicKey)
+// VerifyMidtransSignature verifies the signature_key of a Midtrans notification
+// Format: LowerCase(HexEncode(SHA-512(order_id + status_code + gross_amount + server_key)))
+func VerifyMidtransSignature(orderID, statusCode, grossAmount, signatureKey string) bool {
+	if signatureKey == "" {
+		return false
 	}
 
-	// Fallback to symmetric signature (HMAC SHA-512)
-	return verifySymmetricSignature(stringToSign, signature, pakaiLinkConfig.ClientSecret)
+	matched := false
+	for _, credential := range config.GetMidtransConfig().ActiveCredentials(time.Now()) {
+		hash := sha512.Sum512([]byte(orderID + statusCode + grossAmount + credential.ServerKey))
+		if SecureCompare(strings.ToLower(signatureKey), hex.EncodeToString(hash[:])) {
+			matched = true
+		}
+	}
+	return matched
 }
 
-// verifySymmetricSignature verifies symmetric signature using HMAC SHA-512
-func verifySymmetricSignature(stringToSign, signature, secret string) bool {
-	// HMAC SHA-512
-	mac := hmac.New(sha512.New, []byte(secret))
-	mac.Write([]byte(stringToSign))
-	expectedHash := mac.Sum(nil)
-
-	// Encode expected hash to Base64
-	expectedSignature := base64.StdEncoding.EncodeToString(expectedHash)
-
-	// Compare signatures (both are base64 encoded)
-	return signature == expectedSignature
-}
+// VerifyPakaiLinkSignature verifies PakaiLink signature for webhook callback
+// Supports both symmetric (HMAC SHA-512) and asymmetric (RSA SHA-256) signatures
+// Format: <HTTP METHOD> + ":" + <PATH URL CALLBACK> + ":" + LowerCase(HexEncode(SHA-256(Minify(<HTTP BODY>)))) + ":" + <X-TIMESTAMP>
+func VerifyPakaiLinkSignature(method, path, body, timestamp, signature string) bool {
+	pakaiLinkConfig := config.GetPakaiLinkConfig()
 
-// verifyRSASignature verifies RSA signature using SHA-256
-func verifyRSASignature(stringToSign string, signature string, publicKey *rsa.PublicKey) bool {
-	// Decode base64 signature
-	signatureBytes, err := base64.StdEncoding.DecodeString(signature)
+	// Minify request body (key order is kept, same as SNAP partners)
+	minifiedBody, err := snap.Minify([]byte(body))
 	if err != nil {
 		return false
 	}
 
-	// Hash the string to sign
-	hashed := sha256.Sum256([]byte(stringToSign))
+	// Compose string to sign: METHOD:PATH:HASH:TIMESTAMP
+	stringToSign := snap.StringToSign(method, path, minifiedBody, timestamp)
+
+	// Try every active credential (current + next) so keys can be rotated without downtime
+	for _, credential := range pakaiLinkConfig.ActiveCredentials(time.Now()) {
+		// Try RSA verification first if public key is available
+		if credential.RSAPublicKey != nil {
+			if snap.VerifyAsymmetric(stringToSign, signature, credential.RSAPublicKey) {
+				return true
+			}
+			continue
+		}
 
-	// Verify signature
-	err = rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, hashed[:], signatureBytes)
-	return err == nil
+		// Fallback to symmetric signature (HMAC SHA-512)
+		if credential.ClientSecret != "" && snap.VerifySymmetric(stringToSign, signature, credential.ClientSecret) {
+			return true
+		}
+	}
+	return false
 }
 
-// NormalizeStatus converts various status formats to standard format
-func NormalizeStatus(status string) string {
-	successStatuses := []string{"SUCCEEDED", "PAID", "CAPTURED", "SUCCESS", "COMPLETED", "00"}
-	expiredStatuses := []string{"EXPIRED", "CANCELLED", "VOIDED", "FAILED"}
-	pendingStatuses := []string{"PENDING", "IN_PROGRESS", "OPEN"}
+// PakaiLink callback authentication methods, stored as the verification reason
+const (
+	PakaiLinkAuthSignature = "signature"
+	PakaiLinkAuthToken     = "callback_token"
+	PakaiLinkAuthIP        = "ip_allowlist"
+)
 
-	statusUpper := strings.ToUpper(status)
+// VerifyPakaiLinkCallback authenticates a PakaiLink callback and returns the method that succeeded
+// X-SIGNATURE / X-TIMESTAMP are verified when present; callbacks without them must carry the
+// configured callback token or come from an allowlisted IP. Anything else is rejected
+func VerifyPakaiLinkCallback(method, path, body string, header http.Header, clientIP string) (string, error) {
+	pakaiLinkConfig := config.GetPakaiLinkConfig()
 
-	for _, s := range successStatuses {
-		if statusUpper == s {
-			return "success"
+	signature := header.Get("X-SIGNATURE")
+	timestamp := header.Get("X-TIMESTAMP")
+	if signature != "" || timestamp != "" {
+		if signature == "" || timestamp == "" {
+			return PakaiLinkAuthSignature, errors.New("X-SIGNATURE and X-TIMESTAMP must be sent together")
+		}
+		if !VerifyPakaiLinkSignature(method, path, body, timestamp, signature) {
+			return PakaiLinkAuthSignature, errors.New("invalid X-SIGNATURE")
+		}
+		if err := CheckTimestampSkew("PakaiLink", timestamp, config.GetReplayConfig().MaxSkew); err != nil {
+			return PakaiLinkAuthSignature, err
 		}
+		return PakaiLinkAuthSignature, nil
 	}
 
-	for _, e := range expiredStatuses {
-		if statusUpper == e {
-			return "expires"
+	if token := header.Get(pakaiLinkConfig.CallbackTokenHeader); token != "" {
+		tokenConfigured, matched := false, false
+		for _, credential := range pakaiLinkConfig.ActiveCredentials(time.Now()) {
+			if credential.CallbackToken == "" {
+				continue
+			}
+			tokenConfigured = true
+			if SecureCompare(token, credential.CallbackToken) {
+				matched = true
+			}
+		}
+		if tokenConfigured {
+			if !matched {
+				return PakaiLinkAuthToken, fmt.Errorf("invalid %s", pakaiLinkConfig.CallbackTokenHeader)
+			}
+			return PakaiLinkAuthToken, nil
 		}
 	}
 
-	for _, p := range pendingStatuses {
-		if statusUpper == p {
-			return "pending"
+	if len(pakaiLinkConfig.AllowedIPs) > 0 {
+		if !IPAllowed(clientIP, pakaiLinkConfig.AllowedIPs) {
+			return PakaiLinkAuthIP, fmt.Errorf("source IP %s is not allowlisted", clientIP)
 		}
+		return PakaiLinkAuthIP, nil
 	}
 
-	return "success" // Default
+	return "", errors.New("no X-SIGNATURE, callback token or allowlisted IP")
 }
 
-// MerchantNormalizeStatus converts status to merchant format
-// Returns: "Success", "Pending", or "Failed" (always with capital first letter)
-func MerchantNormalizeStatus(status string) string {
-	successStatuses := []string{"SUCCEEDED", "PAID", "CAPTURED", "SUCCESS", "COMPLETED", "00"}
-	expiredStatuses := []string{"EXPIRED", "CANCELLED", "VOIDED", "FAILED"}
-	pendingStatuses := []string{"PENDING", "IN_PROGRESS", "OPEN"}
-
-	statusUpper := strings.ToUpper(strings.TrimSpace(status))
-
-	for _, s := range successStatuses {
-		if statusUpper == s {
-			return "Success"
-		}
+// CheckTimestampSkew rejects a signed callback whose X-TIMESTAMP (ISO 8601) is more than maxSkew
+// away from the server clock, so a captured callback cannot be replayed later
+func CheckTimestampSkew(provider, timestamp string, maxSkew time.Duration) error {
+	sentAt, err := time.Parse(time.RFC3339, strings.TrimSpace(timestamp))
+	if err != nil {
+		ReplayMetrics.Add(provider+".timestamp_skew", 1)
+		return fmt.Errorf("invalid X-TIMESTAMP %q", timestamp)
 	}
 
-	for _, e := range expiredStatuses {
-		if statusUpper == e {
-			return "Failed"
-		}
+	skew := time.Since(sentAt)
+	if skew < -maxSkew || skew > maxSkew {
+		ReplayMetrics.Add(provider+".timestamp_skew", 1)
+		return fmt.Errorf("%w: %s", ErrTimestampSkew, skew.Round(time.Second))
 	}
+	return nil
+}
 
-	for _, p := range pendingStatuses {
-		if statusUpper == p {
-			return "Pending"
-		}
-	}
+// CheckReplay remembers the provider reference + signature of an accepted callback
+// and returns ErrReplayDetected when the same pair was already seen within the nonce TTL
+func CheckReplay(store NonceStore, provider, reference, signature string) error {
+	ReplayMetrics.Add(provider+".checked", 1)
 
-	// If status is lowercase or mixed case, normalize it
-	statusLower := strings.ToLower(status)
-	if statusLower == "success" || statusLower == "succeeded" || statusLower == "paid" || statusLower == "completed" {
-		return "Success"
-	}
-	if statusLower == "pending" || statusLower == "in_progress" || statusLower == "open" {
-		return "Pending"
+	fresh, err := store.Remember(provider, reference, signature, time.Now().Add(config.GetReplayConfig().NonceTTL))
+	if err != nil {
+		return err
 	}
-	if statusLower == "failed" || statusLower == "expired" || statusLower == "cancelled" || statusLower == "voided" || statusLower == "blocked" {
-		return "Failed"
+	if !fresh {
+		ReplayMetrics.Add(provider+".replayed", 1)
+		return ErrReplayDetected
 	}
-
-	return "Pending" // Default to Pending for unknown statuses
+	return nil
 }
-
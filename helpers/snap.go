@@ -0,0 +1,83 @@
+package helpers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/kytapay/webhook-v2/config"
+	"github.com/kytapay/webhook-v2/pkg/snap"
+)
+
+// ErrDuplicateExternalID is returned when a SNAP partner reuses X-EXTERNAL-ID on the same day
+var ErrDuplicateExternalID = errors.New("X-EXTERNAL-ID already used today")
+
+// ExternalIDStore remembers the X-EXTERNAL-ID values a partner used per day (see repositories.SnapExternalIDRepository)
+type ExternalIDStore interface {
+	Claim(provider, day, externalID string) (bool, error)
+}
+
+// VerifySnapRequest authenticates an inbound SNAP BI request from a configured partner:
+// mandatory headers, X-PARTNER-ID, CHANNEL-ID, X-TIMESTAMP skew and X-SIGNATURE against every active credential
+// Returned errors are *snap.Error so the caller can answer with the SNAP response code
+func VerifySnapRequest(partnerCode, method, path string, body []byte, header http.Header) (*snap.Headers, error) {
+	partner := config.GetSnapPartner(partnerCode)
+	if partner == nil {
+		return nil, snap.Unauthorized("Unknown partner")
+	}
+
+	headers, snapErr := snap.ParseHeaders(header)
+	if snapErr != nil {
+		return nil, snapErr
+	}
+
+	if partner.PartnerID == "" || !SecureCompare(headers.PartnerID, partner.PartnerID) {
+		return nil, snap.Unauthorized("Unknown " + snap.HeaderPartnerID)
+	}
+
+	if len(partner.ChannelIDs) > 0 {
+		allowed := false
+		for _, channelID := range partner.ChannelIDs {
+			if headers.ChannelID == channelID {
+				allowed = true
+			}
+		}
+		if !allowed {
+			return nil, snap.Unauthorized("Unknown " + snap.HeaderChannelID)
+		}
+	}
+
+	if err := CheckTimestampSkew(partner.Code, headers.Timestamp, config.GetReplayConfig().MaxSkew); err != nil {
+		return nil, snap.Unauthorized(err.Error())
+	}
+
+	minifiedBody, err := snap.Minify(body)
+	if err != nil {
+		return nil, snap.InvalidFieldFormat("request body")
+	}
+	stringToSign := snap.StringToSign(method, path, minifiedBody, headers.Timestamp)
+
+	// Try every active credential (current + next) so keys can be rotated without downtime
+	for _, credential := range partner.ActiveCredentials(time.Now()) {
+		if credential.RSAPublicKey != nil && snap.VerifyAsymmetric(stringToSign, headers.Signature, credential.RSAPublicKey) {
+			return headers, nil
+		}
+		if credential.ClientSecret != "" && snap.VerifySymmetric(stringToSign, headers.Signature, credential.ClientSecret) {
+			return headers, nil
+		}
+	}
+	return nil, snap.Unauthorized("Invalid Signature")
+}
+
+// CheckExternalID claims the X-EXTERNAL-ID of a verified SNAP request for its day
+// and returns ErrDuplicateExternalID when the partner already used it
+func CheckExternalID(store ExternalIDStore, provider, day, externalID string) error {
+	fresh, err := store.Claim(provider, day, externalID)
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		return ErrDuplicateExternalID
+	}
+	return nil
+}
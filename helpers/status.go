@@ -0,0 +1,63 @@
+package helpers
+
+import (
+	"expvar"
+
+	"github.com/kytapay/webhook-v2/config"
+	"github.com/kytapay/webhook-v2/models"
+)
+
+// StatusMetrics counts provider statuses that were not in the status mapping, keyed <provider>.<channel>
+var StatusMetrics = expvar.NewMap("webhook_unmapped_status")
+
+// StatusOutcome is the meaning of a provider status for the settlement pipeline
+type StatusOutcome string
+
+// MapStatus resolves a provider status through the configured status mapping
+// Unmapped statuses resolve to review, so an unknown status never moves money
+func MapStatus(provider, channel, status string) (StatusOutcome, bool) {
+	outcome, ok := config.GetStatusMapping().Resolve(provider, channel, status)
+	if !ok {
+		StatusMetrics.Add(provider+"."+channel, 1)
+		return StatusOutcome(config.StatusOutcomeReview), false
+	}
+	return StatusOutcome(outcome), true
+}
+
+// Review reports whether the event has to be held for manual review
+func (o StatusOutcome) Review() bool {
+	return o == config.StatusOutcomeReview
+}
+
+// Captured reports whether the payment is settled by a later settlement callback
+func (o StatusOutcome) Captured() bool {
+	return o == config.StatusOutcomeCaptured
+}
+
+// TransactionStatus is the app_transactions_infos status: "success", "pending", "expires" or "refunded"
+func (o StatusOutcome) TransactionStatus() string {
+	switch o {
+	case config.StatusOutcomeSuccess, config.StatusOutcomeCaptured:
+		return "success"
+	case config.StatusOutcomeFailed:
+		return "expires"
+	case config.StatusOutcomeRefunded:
+		return "refunded"
+	default:
+		return "pending"
+	}
+}
+
+// MerchantStatus is the merchant facing status, a models.Lifecycle* value: "Success", "Pending", "Failed" or "Refunded"
+func (o StatusOutcome) MerchantStatus() string {
+	switch o {
+	case config.StatusOutcomeSuccess, config.StatusOutcomeCaptured:
+		return models.LifecycleSuccess
+	case config.StatusOutcomeFailed:
+		return models.LifecycleFailed
+	case config.StatusOutcomeRefunded:
+		return models.LifecycleRefunded
+	default:
+		return models.LifecyclePending
+	}
+}
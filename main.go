@@ -1,22 +1,57 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/joho/godotenv"
 	"github.com/kytapay/webhook-v2/config"
 	"github.com/kytapay/webhook-v2/controllers"
+	"github.com/kytapay/webhook-v2/helpers"
 	"github.com/kytapay/webhook-v2/routes"
+	"github.com/kytapay/webhook-v2/services"
 )
 
 func main() {
+	hashSecret := flag.Bool("hash-secret", false, "read a secret from stdin, print its salted hash (for LINKQU_CLIENT_SECRET_HASH) and exit")
+	checkConfig := flag.Bool("check-config", false, "validate the configuration, print a report and exit non-zero on errors")
+	flag.Parse()
+
+	if *hashSecret {
+		runHashSecret()
+		return
+	}
+
 	// Load environment variables
-	if err := godotenv.Load(); err != nil {
+	if err := config.LoadEnvFile(".env"); err != nil {
 		log.Println("No .env file found, using environment variables")
 	}
 
+	// Validate provider configuration and refuse to start on errors
+	report := config.ValidateConfig()
+	if *checkConfig {
+		fmt.Println(report)
+		if report.HasErrors() {
+			os.Exit(1)
+		}
+		return
+	}
+	for _, issue := range report.Issues {
+		log.Printf("Config %s: %s %s: %s", issue.Severity, issue.Provider, issue.Field, issue.Message)
+	}
+	if report.HasErrors() {
+		log.Fatal("Invalid configuration, run with -check-config for the full report")
+	}
+
 	// Initialize database
 	db, err := config.InitDB()
 	if err != nil {
@@ -27,25 +62,82 @@ func main() {
 	// Initialize Gin router
 	r := gin.Default()
 
+	// Only trust X-Forwarded-For / X-Real-IP from our own proxies, so ClientIP cannot be spoofed
+	if err := r.SetTrustedProxies(config.GetNetworkConfig().TrustedProxies); err != nil {
+		log.Fatal("Invalid TRUSTED_PROXIES:", err)
+	}
+
 	// Middleware
 	r.Use(gin.Logger())
 	r.Use(gin.Recovery())
 
 	// Initialize controllers
 	webhookController := controllers.NewWebhookController(db)
+	adminController := controllers.NewAdminController(db, webhookController.CallbackDelivery())
+	snapController := controllers.NewSnapController(db)
 
 	// Setup routes
-	routes.SetupRoutes(r, webhookController)
+	routes.SetupRoutes(r, webhookController, adminController, snapController)
+
+	// Start webhook worker (processes queued callbacks in the background)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	eventWorker := webhookController.EventWorker()
+	eventWorker.Start(ctx)
+
+	// Start merchant callback retry scheduler
+	callbackDelivery := webhookController.CallbackDelivery()
+	callbackDelivery.Start(ctx)
+
+	// Reload provider credentials on SIGHUP or when .env / key files change
+	credentialReloader := services.NewCredentialReloader()
+	credentialReloader.Start(ctx)
 
 	// Get port from environment or use default
-	port := os.Getenv("WEBHOOK_PORT")
+	port := config.Getenv("WEBHOOK_PORT")
 	if port == "" {
 		port = "8081" // Default port for webhook service
 	}
 
-	log.Printf("Webhook service starting on port %s", port)
-	if err := r.Run(":" + port); err != nil {
-		log.Fatal("Failed to start webhook service:", err)
+	srv := &http.Server{
+		Addr:    ":" + port,
+		Handler: r,
+	}
+
+	go func() {
+		log.Printf("Webhook service starting on port %s", port)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatal("Failed to start webhook service:", err)
+		}
+	}()
+
+	// Wait for shutdown signal, then stop accepting requests and let workers finish in-flight events
+	<-ctx.Done()
+	log.Println("Shutting down webhook service...")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Println("Failed to shut down HTTP server gracefully:", err)
 	}
+
+	eventWorker.Wait()
+	callbackDelivery.Wait()
+	credentialReloader.Wait()
+	log.Println("Webhook service stopped")
 }
 
+// runHashSecret prints a salted hash of the secret read from stdin
+func runHashSecret() {
+	secret, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && secret == "" {
+		log.Fatal("Failed to read secret from stdin:", err)
+	}
+
+	hash, err := helpers.HashSecret(strings.TrimRight(secret, "\r\n"))
+	if err != nil {
+		log.Fatal("Failed to hash secret:", err)
+	}
+	fmt.Println(hash)
+}
@@ -0,0 +1,36 @@
+package middlewares
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kytapay/webhook-v2/config"
+)
+
+// AdminAuth protects admin routes with the static bearer token from ADMIN_API_TOKEN
+// Admin routes are disabled entirely while no token is configured
+func AdminAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		expected := config.GetAdminConfig().APIToken
+		if expected == "" {
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{
+				"success": false,
+				"message": "Admin API is disabled",
+			})
+			return
+		}
+
+		token := strings.TrimSpace(strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer "))
+		if subtle.ConstantTimeCompare([]byte(token), []byte(expected)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"message": "Unauthorized",
+			})
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,14 @@
+-- Double-entry ledger behind every wallet movement
+CREATE TABLE IF NOT EXISTS ledger_entries (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    grant_id VARCHAR(191) NOT NULL,
+    user_id INT UNSIGNED NOT NULL,
+    account VARCHAR(50) NOT NULL,
+    direction ENUM('debit', 'credit') NOT NULL,
+    amount DECIMAL(20, 2) NOT NULL,
+    description VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NULL DEFAULT NULL,
+    PRIMARY KEY (id),
+    KEY ledger_entries_grant_id_index (grant_id),
+    KEY ledger_entries_user_account_index (user_id, account)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
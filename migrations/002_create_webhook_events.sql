@@ -0,0 +1,19 @@
+-- Inbound provider callbacks, stored before processing
+CREATE TABLE IF NOT EXISTS webhook_events (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    provider VARCHAR(50) NOT NULL,
+    channel VARCHAR(50) NOT NULL,
+    route VARCHAR(255) NOT NULL,
+    source_ip VARCHAR(45) NOT NULL,
+    headers TEXT NOT NULL,
+    body MEDIUMTEXT NOT NULL,
+    partner_reference VARCHAR(191) NULL DEFAULT NULL,
+    verification_status VARCHAR(20) NOT NULL,
+    processing_status VARCHAR(20) NOT NULL,
+    processing_message TEXT NULL DEFAULT NULL,
+    created_at TIMESTAMP NULL DEFAULT NULL,
+    updated_at TIMESTAMP NULL DEFAULT NULL,
+    PRIMARY KEY (id),
+    KEY webhook_events_partner_reference_index (partner_reference),
+    KEY webhook_events_created_at_index (created_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
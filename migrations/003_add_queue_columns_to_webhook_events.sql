@@ -0,0 +1,12 @@
+-- Turn webhook_events into the durable processing queue
+ALTER TABLE webhook_events
+    ADD COLUMN event_type VARCHAR(20) NULL DEFAULT NULL AFTER processing_message,
+    ADD COLUMN event_status VARCHAR(50) NULL DEFAULT NULL AFTER event_type,
+    ADD COLUMN amount DECIMAL(20, 2) NOT NULL DEFAULT 0 AFTER event_status,
+    ADD COLUMN event_time VARCHAR(50) NULL DEFAULT NULL AFTER amount,
+    ADD COLUMN method VARCHAR(50) NULL DEFAULT NULL AFTER event_time,
+    ADD COLUMN attempts INT UNSIGNED NOT NULL DEFAULT 0 AFTER method,
+    ADD COLUMN available_at TIMESTAMP NULL DEFAULT NULL AFTER attempts,
+    ADD COLUMN locked_by VARCHAR(100) NULL DEFAULT NULL AFTER available_at,
+    ADD COLUMN locked_at TIMESTAMP NULL DEFAULT NULL AFTER locked_by,
+    ADD KEY webhook_events_queue_index (processing_status, available_at);
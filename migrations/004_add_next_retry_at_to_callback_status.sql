@@ -0,0 +1,4 @@
+-- Schedule for automatic merchant callback retries
+ALTER TABLE callback_status
+    ADD COLUMN next_retry_at TIMESTAMP NULL DEFAULT NULL AFTER retry_count,
+    ADD KEY callback_status_retry_index (status, next_retry_at);
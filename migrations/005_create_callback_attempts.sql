@@ -0,0 +1,19 @@
+-- Every merchant callback delivery try
+CREATE TABLE IF NOT EXISTS callback_attempts (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    callback_status_id INT UNSIGNED NOT NULL,
+    transaction_info_id INT UNSIGNED NOT NULL,
+    attempt_number INT UNSIGNED NOT NULL,
+    url VARCHAR(2048) NOT NULL,
+    request_headers TEXT NOT NULL,
+    request_payload MEDIUMTEXT NOT NULL,
+    http_status SMALLINT UNSIGNED NULL DEFAULT NULL,
+    response_snippet TEXT NULL DEFAULT NULL,
+    duration_ms INT UNSIGNED NOT NULL DEFAULT 0,
+    error_class VARCHAR(20) NOT NULL,
+    error_message TEXT NULL DEFAULT NULL,
+    created_at TIMESTAMP NULL DEFAULT NULL,
+    PRIMARY KEY (id),
+    KEY callback_attempts_transaction_info_id_index (transaction_info_id),
+    KEY callback_attempts_callback_status_id_index (callback_status_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
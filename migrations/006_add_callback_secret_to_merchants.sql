@@ -0,0 +1,4 @@
+-- Per-merchant secret for signing outbound callbacks (X-Signature / X-Timestamp)
+-- Merchants without a secret keep receiving unsigned callbacks
+ALTER TABLE merchants
+    ADD COLUMN callback_secret VARCHAR(128) NULL DEFAULT NULL AFTER status;
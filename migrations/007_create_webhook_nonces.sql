@@ -0,0 +1,12 @@
+-- Replay cache for signed inbound callbacks: one row per provider reference + signature
+CREATE TABLE IF NOT EXISTS webhook_nonces (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    provider VARCHAR(50) NOT NULL,
+    reference VARCHAR(191) NOT NULL,
+    signature_hash CHAR(64) NOT NULL,
+    expires_at TIMESTAMP NULL DEFAULT NULL,
+    created_at TIMESTAMP NULL DEFAULT NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY webhook_nonces_provider_reference_signature_unique (provider, reference, signature_hash),
+    KEY webhook_nonces_expires_at_index (expires_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
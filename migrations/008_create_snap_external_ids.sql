@@ -0,0 +1,11 @@
+-- SNAP BI X-EXTERNAL-ID registry: an external id may be used once per partner per day
+CREATE TABLE IF NOT EXISTS snap_external_ids (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    provider VARCHAR(50) NOT NULL,
+    external_id VARCHAR(36) NOT NULL,
+    request_date DATE NOT NULL,
+    created_at TIMESTAMP NULL DEFAULT NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY snap_external_ids_provider_date_external_id_unique (provider, request_date, external_id),
+    KEY snap_external_ids_request_date_index (request_date)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,6 @@
+-- Result of comparing the amount in the payment callback with the order amount
+-- amount_status: matched, mismatch (settled with the order amount), partial (settled with the paid amount) or rejected
+ALTER TABLE app_transactions_infos
+    ADD COLUMN paid_amount DECIMAL(20, 2) NULL DEFAULT NULL AFTER amount,
+    ADD COLUMN amount_status VARCHAR(20) NULL DEFAULT NULL AFTER paid_amount,
+    ADD KEY app_transactions_infos_amount_status_index (amount_status);
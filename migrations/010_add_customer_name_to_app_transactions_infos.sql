@@ -0,0 +1,4 @@
+-- Payer name of a virtual account, returned as virtualAccountName in SNAP VA inquiries
+-- Filled when the VA is created; VAs without it are answered with the merchant name and alerted
+ALTER TABLE app_transactions_infos
+    ADD COLUMN customer_name VARCHAR(255) NULL DEFAULT NULL AFTER bank_ewallet_name;
@@ -0,0 +1,3 @@
+-- Operator override of AMOUNT_MISMATCH_POLICY for one requeued event (flag or partial)
+ALTER TABLE webhook_events
+    ADD COLUMN amount_policy VARCHAR(20) NULL DEFAULT NULL AFTER method;
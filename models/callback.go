@@ -2,6 +2,13 @@ package models
 
 import "time"
 
+// Callback delivery statuses
+const (
+	CallbackStatusSuccess  = "Success"
+	CallbackStatusFailed   = "Failed"
+	CallbackStatusRetrying = "Retrying"
+)
+
 type CallbackStatus struct {
 	ID                int        `json:"id" db:"id"`
 	TransactionInfoID int        `json:"transaction_info_id" db:"transaction_info_id"`
@@ -12,6 +19,7 @@ type CallbackStatus struct {
 	ResponseBody      *string   `json:"response_body" db:"response_body"`
 	Payload           *string   `json:"payload" db:"payload"`
 	RetryCount        int        `json:"retry_count" db:"retry_count"`
+	NextRetryAt       *time.Time `json:"next_retry_at" db:"next_retry_at"`
 	CreatedAt         *time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt         *time.Time `json:"updated_at" db:"updated_at"`
 }
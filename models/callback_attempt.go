@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// Callback attempt error classes
+const (
+	CallbackErrorNone       = "none"
+	CallbackErrorTimeout    = "timeout"
+	CallbackErrorConnection = "connection"
+	CallbackErrorRequest    = "request"
+	CallbackErrorHTTP4xx    = "http_4xx"
+	CallbackErrorHTTP5xx    = "http_5xx"
+	CallbackErrorHTTPOther  = "http_other"
+)
+
+// CallbackAttempt is one delivery try of a merchant callback
+type CallbackAttempt struct {
+	ID                int64      `json:"id" db:"id"`
+	CallbackStatusID  int        `json:"callback_status_id" db:"callback_status_id"`
+	TransactionInfoID int        `json:"transaction_info_id" db:"transaction_info_id"`
+	AttemptNumber     int        `json:"attempt_number" db:"attempt_number"`
+	URL               string     `json:"url" db:"url"`
+	RequestHeaders    string     `json:"request_headers" db:"request_headers"`
+	RequestPayload    string     `json:"request_payload" db:"request_payload"`
+	HTTPStatus        *int       `json:"http_status" db:"http_status"`
+	ResponseSnippet   *string    `json:"response_snippet" db:"response_snippet"`
+	DurationMs        int64      `json:"duration_ms" db:"duration_ms"`
+	ErrorClass        string     `json:"error_class" db:"error_class"`
+	ErrorMessage      *string    `json:"error_message" db:"error_message"`
+	CreatedAt         *time.Time `json:"created_at" db:"created_at"`
+}
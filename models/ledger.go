@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Ledger accounts
+const (
+	LedgerAccountMerchantWallet   = "merchant_wallet"
+	LedgerAccountFeeRevenue       = "fee_revenue"
+	LedgerAccountProviderClearing = "provider_clearing"
+	LedgerAccountOpeningBalance   = "opening_balance"
+)
+
+// Ledger entry directions
+const (
+	LedgerDebit  = "debit"
+	LedgerCredit = "credit"
+)
+
+type LedgerEntry struct {
+	ID          int64      `json:"id" db:"id"`
+	GrantID     string     `json:"grant_id" db:"grant_id"`
+	UserID      int        `json:"user_id" db:"user_id"`
+	Account     string     `json:"account" db:"account"`
+	Direction   string     `json:"direction" db:"direction"`
+	Amount      Money      `json:"amount" db:"amount"`
+	Description string     `json:"description" db:"description"`
+	CreatedAt   *time.Time `json:"created_at" db:"created_at"`
+}
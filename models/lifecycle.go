@@ -0,0 +1,118 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Lifecycle statuses of merchant_payments, merchant_payouts and transactions
+const (
+	LifecyclePending           = "Pending"
+	LifecyclePendingSettlement = "Pending_Settlement"
+	LifecycleSuccess           = "Success"
+	LifecycleFailed            = "Failed"
+	LifecycleRefunded          = "Refunded"
+)
+
+// CallbackPaymentSettled is the status sent in the merchant callback when a payment is settled;
+// merchant_payments stays Success, only the transactions row moves from Pending_Settlement to Success
+const CallbackPaymentSettled = "Settled"
+
+// Side effects of a lifecycle transition, executed by the settlement pipeline in the same DB transaction
+const (
+	// EffectMoveFunds credits (payment) or debits (payout) the merchant wallet and writes ledger entries
+	EffectMoveFunds = "move_funds"
+	// EffectReverseFunds reverses every ledger leg written for the grant and the wallet balance with it
+	EffectReverseFunds = "reverse_funds"
+	// EffectNotifyMerchant sends the merchant callback after commit
+	EffectNotifyMerchant = "notify_merchant"
+)
+
+var (
+	// ErrSameStatus is returned for a transition to the current status (a duplicate callback)
+	ErrSameStatus = errors.New("status unchanged")
+	// ErrTransitionNotAllowed is returned for a transition that is not in the state machine
+	ErrTransitionNotAllowed = errors.New("status transition not allowed")
+)
+
+// Transition is one allowed status change and its side effects
+type Transition struct {
+	From    string
+	To      string
+	Effects []string
+}
+
+// StateMachine lists the allowed status transitions of one table
+type StateMachine struct {
+	Name        string
+	transitions map[string]map[string][]string
+}
+
+func newStateMachine(name string, transitions []Transition) *StateMachine {
+	m := &StateMachine{Name: name, transitions: map[string]map[string][]string{}}
+	for _, t := range transitions {
+		from := strings.ToUpper(t.From)
+		if m.transitions[from] == nil {
+			m.transitions[from] = map[string][]string{}
+		}
+		m.transitions[from][strings.ToUpper(t.To)] = t.Effects
+	}
+	return m
+}
+
+// Transition checks a status change (case insensitive) and returns its side effects
+func (m *StateMachine) Transition(from, to string) ([]string, error) {
+	if strings.EqualFold(from, to) {
+		return nil, ErrSameStatus
+	}
+
+	effects, ok := m.transitions[strings.ToUpper(from)][strings.ToUpper(to)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s %s -> %s", ErrTransitionNotAllowed, m.Name, from, to)
+	}
+	return effects, nil
+}
+
+// HasEffect reports whether effects contains effect
+func HasEffect(effects []string, effect string) bool {
+	for _, e := range effects {
+		if e == effect {
+			return true
+		}
+	}
+	return false
+}
+
+// PaymentLifecycle is the state machine of merchant_payments.status
+var PaymentLifecycle = newStateMachine("merchant_payments", []Transition{
+	{LifecyclePending, LifecycleSuccess, []string{EffectMoveFunds, EffectNotifyMerchant}},
+	{LifecyclePending, LifecycleFailed, []string{EffectNotifyMerchant}},
+	// Late success after the provider reported an expiry / failure
+	{LifecycleFailed, LifecycleSuccess, []string{EffectMoveFunds, EffectNotifyMerchant}},
+	{LifecycleSuccess, LifecycleRefunded, []string{EffectReverseFunds, EffectNotifyMerchant}},
+})
+
+// PayoutLifecycle is the state machine of merchant_payouts.status
+var PayoutLifecycle = newStateMachine("merchant_payouts", []Transition{
+	{LifecyclePending, LifecycleSuccess, []string{EffectMoveFunds, EffectNotifyMerchant}},
+	{LifecyclePending, LifecycleFailed, []string{EffectNotifyMerchant}},
+	// Late success after the provider reported a failure
+	{LifecycleFailed, LifecycleSuccess, []string{EffectMoveFunds, EffectNotifyMerchant}},
+	// Returned by the beneficiary bank after it was reported successful
+	{LifecycleSuccess, LifecycleFailed, []string{EffectReverseFunds, EffectNotifyMerchant}},
+})
+
+// TransactionLifecycle is the state machine of transactions.status, shared by payments and payouts
+// Its side effects are carried by the merchant_payments / merchant_payouts transition
+var TransactionLifecycle = newStateMachine("transactions", []Transition{
+	{LifecyclePending, LifecycleSuccess, nil},
+	{LifecyclePending, LifecyclePendingSettlement, nil},
+	{LifecyclePending, LifecycleFailed, nil},
+	{LifecycleFailed, LifecycleSuccess, nil},
+	{LifecycleFailed, LifecyclePendingSettlement, nil},
+	{LifecyclePendingSettlement, LifecycleSuccess, nil},
+	{LifecyclePendingSettlement, LifecycleRefunded, nil},
+	{LifecycleSuccess, LifecycleRefunded, nil},
+	{LifecycleSuccess, LifecycleFailed, nil},
+})
@@ -1,12 +1,13 @@
 package models
 
 type Merchant struct {
-	ID           int     `json:"id" db:"id"`
-	UserID       int     `json:"user_id" db:"user_id"`
-	BusinessName string  `json:"business_name" db:"business_name"`
-	MerchantUUID *string `json:"merchant_uuid" db:"merchant_uuid"`
-	SiteURL      *string `json:"site_url" db:"site_url"`
-	Status       string  `json:"status" db:"status"`
+	ID             int     `json:"id" db:"id"`
+	UserID         int     `json:"user_id" db:"user_id"`
+	BusinessName   string  `json:"business_name" db:"business_name"`
+	MerchantUUID   *string `json:"merchant_uuid" db:"merchant_uuid"`
+	SiteURL        *string `json:"site_url" db:"site_url"`
+	Status         string  `json:"status" db:"status"`
+	CallbackSecret *string `json:"-" db:"callback_secret"`
 }
 
 type MerchantPayment struct {
@@ -17,11 +18,11 @@ type MerchantPayment struct {
 	OrderNo         *string   `json:"order_no" db:"order_no"`
 	UUID            *string   `json:"uuid" db:"uuid"`
 	FeeBearer       string    `json:"fee_bearer" db:"fee_bearer"`
-	Percentage      float64   `json:"percentage" db:"percentage"`
-	ChargePercentage float64  `json:"charge_percentage" db:"charge_percentage"`
-	ChargeFixed     float64   `json:"charge_fixed" db:"charge_fixed"`
-	Amount          float64   `json:"amount" db:"amount"`
-	Total           float64   `json:"total" db:"total"`
+	Percentage      Rate      `json:"percentage" db:"percentage"`
+	ChargePercentage Money    `json:"charge_percentage" db:"charge_percentage"`
+	ChargeFixed     Money     `json:"charge_fixed" db:"charge_fixed"`
+	Amount          Money     `json:"amount" db:"amount"`
+	Total           Money     `json:"total" db:"total"`
 	Status          string    `json:"status" db:"status"`
 	CreatedAt       *string   `json:"created_at" db:"created_at"`
 	UpdatedAt       *string   `json:"updated_at" db:"updated_at"`
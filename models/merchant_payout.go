@@ -13,11 +13,11 @@ type MerchantPayout struct {
 	ItemName        *string   `json:"item_name" db:"item_name"`
 	UUID            *string   `json:"uuid" db:"uuid"`
 	FeeBearer       string    `json:"fee_bearer" db:"fee_bearer"`
-	Percentage      float64   `json:"percentage" db:"percentage"`
-	ChargePercentage float64  `json:"charge_percentage" db:"charge_percentage"`
-	ChargeFixed     float64   `json:"charge_fixed" db:"charge_fixed"`
-	Amount          float64   `json:"amount" db:"amount"`
-	Total           float64   `json:"total" db:"total"`
+	Percentage      Rate      `json:"percentage" db:"percentage"`
+	ChargePercentage Money    `json:"charge_percentage" db:"charge_percentage"`
+	ChargeFixed     Money     `json:"charge_fixed" db:"charge_fixed"`
+	Amount          Money     `json:"amount" db:"amount"`
+	Total           Money     `json:"total" db:"total"`
 	Status          string    `json:"status" db:"status"`
 	CreatedAt       *time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt       *time.Time `json:"updated_at" db:"updated_at"`
@@ -0,0 +1,231 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Money is an exact amount of rupiah stored in minor units (1/100 rupiah)
+// Never convert to float64 for arithmetic; use the methods below instead
+type Money int64
+
+// moneyDecimals is the number of decimal places kept by Money
+const moneyDecimals = 2
+
+// Rate is an exact percentage with six decimal places (1.5% is Rate(1500000))
+type Rate int64
+
+// rateDecimals is the number of decimal places kept by Rate
+const rateDecimals = 6
+
+// Rupiah returns Money for a whole rupiah amount
+func Rupiah(rupiah int64) Money {
+	return Money(rupiah * 100)
+}
+
+// ParseMoney parses a decimal string such as "10000", "10000.5" or "-10000.50"
+// Extra decimal places are rounded half away from zero
+func ParseMoney(s string) (Money, error) {
+	value, err := parseDecimal(s, moneyDecimals)
+	if err != nil {
+		return 0, fmt.Errorf("invalid money amount %q: %v", s, err)
+	}
+	return Money(value), nil
+}
+
+// MoneyFromFloat converts a float amount, rounding half away from zero to the nearest cent
+// Only use this at boundaries where the source already is a float (e.g. a JSON number)
+func MoneyFromFloat(f float64) Money {
+	value, err := parseDecimal(strconv.FormatFloat(f, 'f', -1, 64), moneyDecimals)
+	if err != nil {
+		return Money(math.Round(f * 100))
+	}
+	return Money(value)
+}
+
+// Rupiah returns the amount in whole rupiah, rounding half away from zero
+func (m Money) Rupiah() int64 {
+	return roundDiv(big.NewInt(int64(m)), big.NewInt(100)).Int64()
+}
+
+// Percent returns rate percent of m, rounded half away from zero to the nearest cent
+func (m Money) Percent(rate Rate) Money {
+	product := new(big.Int).Mul(big.NewInt(int64(m)), big.NewInt(int64(rate)))
+	divisor := new(big.Int).Mul(big.NewInt(100), pow10(rateDecimals))
+	return Money(roundDiv(product, divisor).Int64())
+}
+
+// Float64 returns the amount as float64 (for display only)
+func (m Money) Float64() float64 {
+	return float64(m) / 100
+}
+
+// String formats the amount with two decimal places, e.g. "10000.50"
+func (m Money) String() string {
+	return formatDecimal(int64(m), moneyDecimals)
+}
+
+// MarshalJSON encodes Money as a JSON number with two decimal places
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON accepts both JSON numbers and numeric strings
+func (m *Money) UnmarshalJSON(data []byte) error {
+	var number json.Number
+	if err := json.Unmarshal(data, &number); err != nil {
+		return err
+	}
+	parsed, err := ParseMoney(number.String())
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// Scan implements sql.Scanner for DECIMAL and INT columns
+func (m *Money) Scan(src interface{}) error {
+	value, err := scanDecimal(src, moneyDecimals)
+	if err != nil {
+		return fmt.Errorf("scan money: %v", err)
+	}
+	*m = Money(value)
+	return nil
+}
+
+// Value implements driver.Valuer, sending the amount as an exact decimal string
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
+
+// ParseRate parses a percentage such as "1.5" or "0.7"
+func ParseRate(s string) (Rate, error) {
+	value, err := parseDecimal(s, rateDecimals)
+	if err != nil {
+		return 0, fmt.Errorf("invalid rate %q: %v", s, err)
+	}
+	return Rate(value), nil
+}
+
+// MustParseRate is ParseRate for constants; it panics on invalid input
+func MustParseRate(s string) Rate {
+	rate, err := ParseRate(s)
+	if err != nil {
+		panic(err)
+	}
+	return rate
+}
+
+// String formats the rate without trailing zeros, e.g. "1.5"
+func (r Rate) String() string {
+	s := formatDecimal(int64(r), rateDecimals)
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+// MarshalJSON encodes Rate as a JSON number
+func (r Rate) MarshalJSON() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// Scan implements sql.Scanner for DECIMAL columns
+func (r *Rate) Scan(src interface{}) error {
+	value, err := scanDecimal(src, rateDecimals)
+	if err != nil {
+		return fmt.Errorf("scan rate: %v", err)
+	}
+	*r = Rate(value)
+	return nil
+}
+
+// Value implements driver.Valuer, sending the rate as an exact decimal string
+func (r Rate) Value() (driver.Value, error) {
+	return formatDecimal(int64(r), rateDecimals), nil
+}
+
+// scanDecimal converts a database value into a scaled integer
+func scanDecimal(src interface{}, decimals int) (int64, error) {
+	switch v := src.(type) {
+	case nil:
+		return 0, nil
+	case []byte:
+		return parseDecimal(string(v), decimals)
+	case string:
+		return parseDecimal(v, decimals)
+	case int64:
+		return v * pow10(decimals).Int64(), nil
+	case float64:
+		return parseDecimal(strconv.FormatFloat(v, 'f', -1, 64), decimals)
+	default:
+		return 0, fmt.Errorf("unsupported type %T", src)
+	}
+}
+
+// parseDecimal parses a decimal string into an integer scaled by 10^decimals
+// Digits beyond the scale are rounded half away from zero
+func parseDecimal(s string, decimals int) (int64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, fmt.Errorf("empty value")
+	}
+
+	// big.Rat also accepts fractions ("1/3") and base prefixes ("0x10"), which are not amounts
+	if strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= '0' && r <= '9' || r == '.' || r == '-' || r == '+' || r == 'e' || r == 'E')
+	}) >= 0 {
+		return 0, fmt.Errorf("not a decimal number")
+	}
+
+	rat, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return 0, fmt.Errorf("not a decimal number")
+	}
+
+	scaled := new(big.Int).Mul(rat.Num(), pow10(decimals))
+	result := roundDiv(scaled, rat.Denom())
+	if !result.IsInt64() {
+		return 0, fmt.Errorf("value out of range")
+	}
+	return result.Int64(), nil
+}
+
+// formatDecimal formats an integer scaled by 10^decimals as a decimal string
+func formatDecimal(value int64, decimals int) string {
+	sign := ""
+	if value < 0 {
+		sign = "-"
+	}
+
+	digits := new(big.Int).Abs(big.NewInt(value)).String()
+	if len(digits) <= decimals {
+		digits = strings.Repeat("0", decimals-len(digits)+1) + digits
+	}
+
+	point := len(digits) - decimals
+	return sign + digits[:point] + "." + digits[point:]
+}
+
+// roundDiv divides a by b, rounding half away from zero
+func roundDiv(a, b *big.Int) *big.Int {
+	quotient, remainder := new(big.Int).QuoRem(a, b, new(big.Int))
+	twice := new(big.Int).Mul(new(big.Int).Abs(remainder), big.NewInt(2))
+	if twice.Cmp(new(big.Int).Abs(b)) >= 0 {
+		if (a.Sign() < 0) != (b.Sign() < 0) {
+			quotient.Sub(quotient, big.NewInt(1))
+		} else {
+			quotient.Add(quotient, big.NewInt(1))
+		}
+	}
+	return quotient
+}
+
+// pow10 returns 10^n as big.Int
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
@@ -0,0 +1,216 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Money
+		err   bool
+	}{
+		{input: "10000", want: Rupiah(10000)},
+		{input: "10000.5", want: 1000050},
+		{input: "10000.50", want: 1000050},
+		{input: " 75000.00 ", want: Rupiah(75000)},
+		{input: "-10000.50", want: -1000050},
+		{input: "0", want: 0},
+		{input: "10000.005", want: 1000001},
+		{input: "10000.004", want: 1000000},
+		{input: "-10000.005", want: -1000001},
+		{input: "0.125", want: 13},
+		{input: "1.5e4", want: Rupiah(15000)},
+		{input: "", err: true},
+		{input: "abc", err: true},
+		{input: "10,000", err: true},
+		{input: "10.000,50", err: true},
+		{input: "1.000.000", err: true},
+		{input: "Rp 10000", err: true},
+		{input: "1/3", err: true},
+		{input: "0x10", err: true},
+		{input: "100000000000000000000", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseMoney(tt.input)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected an error, got %s", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoneyPercent(t *testing.T) {
+	tests := []struct {
+		amount Money
+		rate   string
+		want   Money
+	}{
+		{Rupiah(10000), "0.7", Rupiah(70)},
+		{Rupiah(10000), "1.5", Rupiah(150)},
+		{Rupiah(12345), "0.7", 8642},   // 86.415 rounds half up
+		{Rupiah(12341), "0.7", 8639},   // 86.387
+		{-Rupiah(12345), "0.7", -8642}, // half away from zero
+		{Rupiah(100), "0.001", 0},      // 0.001 rupiah
+		{Rupiah(500), "0.001", 1},      // 0.005 rupiah
+		{Rupiah(10000), "0", 0},
+		{Rupiah(10000), "100", Rupiah(10000)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.amount.String()+"x"+tt.rate, func(t *testing.T) {
+			if got := tt.amount.Percent(MustParseRate(tt.rate)); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		input  string
+		want   Rate
+		string string
+	}{
+		{"1.5", 1500000, "1.5"},
+		{"0.7", 700000, "0.7"},
+		{"5", 5000000, "5"},
+		{"0.0000005", 1, "0.000001"},
+		{"0.0000004", 0, "0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseRate(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want || got.String() != tt.string {
+				t.Errorf("got %d (%s), want %d (%s)", got, got, tt.want, tt.string)
+			}
+		})
+	}
+
+	if _, err := ParseRate("1,5"); err == nil {
+		t.Error("expected an error for 1,5")
+	}
+}
+
+func TestMoneyRupiah(t *testing.T) {
+	tests := []struct {
+		amount Money
+		want   int64
+	}{
+		{1000049, 10000},
+		{1000050, 10001},
+		{-1000050, -10001},
+		{Rupiah(75000), 75000},
+	}
+
+	for _, tt := range tests {
+		if got := tt.amount.Rupiah(); got != tt.want {
+			t.Errorf("%s.Rupiah() = %d, want %d", tt.amount, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyScan(t *testing.T) {
+	tests := []struct {
+		name string
+		src  interface{}
+		want Money
+		err  bool
+	}{
+		{name: "nil", src: nil, want: 0},
+		{name: "DECIMAL bytes", src: []byte("75000.50"), want: 7500050},
+		{name: "DECIMAL bytes negative", src: []byte("-0.01"), want: -1},
+		{name: "string", src: "10000.00", want: Rupiah(10000)},
+		{name: "INT column", src: int64(10000), want: Rupiah(10000)},
+		{name: "float", src: float64(0.1) + float64(0.2), want: 30},
+		{name: "float rounding", src: float64(10000.125), want: 1000013},
+		{name: "invalid bytes", src: []byte("abc"), err: true},
+		{name: "unsupported type", src: true, err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Money
+			err := got.Scan(tt.src)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected an error, got %s", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	var rate Rate
+	if err := rate.Scan([]byte("0.700000")); err != nil || rate != MustParseRate("0.7") {
+		t.Errorf("rate scan = %s, %v", rate, err)
+	}
+}
+
+func TestMoneyJSON(t *testing.T) {
+	tests := []struct {
+		amount Money
+		want   string
+	}{
+		{Rupiah(10000), "10000.00"},
+		{1000050, "10000.50"},
+		{5, "0.05"},
+		{-5, "-0.05"},
+		{-1000050, "-10000.50"},
+		{0, "0.00"},
+	}
+
+	for _, tt := range tests {
+		data, err := json.Marshal(struct {
+			Amount Money `json:"amount"`
+		}{tt.amount})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := `{"amount":` + tt.want + `}`; string(data) != want {
+			t.Errorf("got %s, want %s", data, want)
+		}
+
+		var decoded struct {
+			Amount Money `json:"amount"`
+		}
+		if err := json.Unmarshal(data, &decoded); err != nil || decoded.Amount != tt.amount {
+			t.Errorf("round trip of %s = %s, %v", tt.want, decoded.Amount, err)
+		}
+	}
+
+	var fromString Money
+	if err := json.Unmarshal([]byte(`"75000.5"`), &fromString); err != nil || fromString != 7500050 {
+		t.Errorf("string amount = %s, %v", fromString, err)
+	}
+	if err := json.Unmarshal([]byte(`"10,000"`), &fromString); err == nil {
+		t.Error("expected an error for 10,000")
+	}
+
+	data, _ := json.Marshal(MustParseRate("1.5"))
+	if string(data) != "1.5" {
+		t.Errorf("rate JSON = %s, want 1.5", data)
+	}
+}
@@ -2,12 +2,20 @@ package models
 
 import "time"
 
+// Amount check results stored in app_transactions_infos.amount_status
+const (
+	AmountStatusMatched  = "matched"
+	AmountStatusMismatch = "mismatch"
+	AmountStatusPartial  = "partial"
+	AmountStatusRejected = "rejected"
+)
+
 type TransactionInfo struct {
 	ID              int        `json:"id" db:"id"`
 	AppID           int        `json:"app_id" db:"app_id"`
 	OrderID         string     `json:"order_id" db:"order_id"`
 	PaymentMethod   string     `json:"payment_method" db:"payment_method"`
-	Amount          int64      `json:"amount" db:"amount"`
+	Amount          Money      `json:"amount" db:"amount"`
 	Currency        string     `json:"currency" db:"currency"`
 	NotifyURL       string     `json:"notify_url" db:"notify_url"`
 	SuccessURL      *string    `json:"success_url" db:"success_url"`
@@ -16,6 +24,8 @@ type TransactionInfo struct {
 	Token           *string    `json:"token" db:"token"`
 	BankNumber      *string    `json:"bank_number" db:"bank_number"`
 	BankEwalletName *string    `json:"bank_ewallet_name" db:"bank_ewallet_name"`
+	// CustomerName is only loaded by the SNAP VA inquiry (TransactionRepository.GetTransactionByBankNumber)
+	CustomerName    *string    `json:"customer_name" db:"customer_name"`
 	QrisString      *string    `json:"qris_string" db:"qris_string"`
 	EwalletLink     *string    `json:"ewallet_link" db:"ewallet_link"`
 	Status          string     `json:"status" db:"status"`
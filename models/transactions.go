@@ -13,11 +13,11 @@ type Transactions struct {
 	TransactionReferenceID int       `json:"transaction_reference_id" db:"transaction_reference_id"`
 	TransactionTypeID     *int       `json:"transaction_type_id" db:"transaction_type_id"`
 	UserType              string     `json:"user_type" db:"user_type"`
-	Subtotal              float64    `json:"subtotal" db:"subtotal"`
-	Percentage            float64    `json:"percentage" db:"percentage"`
-	ChargePercentage      float64    `json:"charge_percentage" db:"charge_percentage"`
-	ChargeFixed           float64    `json:"charge_fixed" db:"charge_fixed"`
-	Total                 float64    `json:"total" db:"total"`
+	Subtotal              Money      `json:"subtotal" db:"subtotal"`
+	Percentage            Rate       `json:"percentage" db:"percentage"`
+	ChargePercentage      Money      `json:"charge_percentage" db:"charge_percentage"`
+	ChargeFixed           Money      `json:"charge_fixed" db:"charge_fixed"`
+	Total                 Money      `json:"total" db:"total"`
 	PaymentStatus         *string    `json:"payment_status" db:"payment_status"`
 	Status                string     `json:"status" db:"status"`
 	CreatedAt             *time.Time `json:"created_at" db:"created_at"`
@@ -34,11 +34,11 @@ type TransactionsData struct {
 	TransactionReferenceID int
 	TransactionTypeID     *int
 	UserType              string
-	Subtotal              float64
-	Percentage            float64
-	ChargePercentage      float64
-	ChargeFixed           float64
-	Total                 float64
+	Subtotal              Money
+	Percentage            Rate
+	ChargePercentage      Money
+	ChargeFixed           Money
+	Total                 Money
 	PaymentStatus         *string
 	Status                string
 }
@@ -5,7 +5,7 @@ import "time"
 type Wallet struct {
 	ID        int        `json:"id" db:"id"`
 	UserID    int        `json:"user_id" db:"user_id"`
-	Balance   float64    `json:"balance" db:"balance"`
+	Balance   Money      `json:"balance" db:"balance"`
 	CreatedAt *time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt *time.Time `json:"updated_at" db:"updated_at"`
 }
@@ -0,0 +1,59 @@
+package models
+
+import "time"
+
+// Webhook event verification results
+const (
+	WebhookVerificationPending  = "pending"
+	WebhookVerificationVerified = "verified"
+	WebhookVerificationFailed   = "failed"
+	WebhookVerificationSkipped  = "skipped"
+)
+
+// Webhook event types, decide which settlement pipeline handles the event
+const (
+	WebhookEventPayment    = "payment"
+	WebhookEventPayout     = "payout"
+	WebhookEventSettlement = "settlement"
+)
+
+// Webhook event processing statuses
+const (
+	WebhookStatusReceived   = "received"
+	WebhookStatusQueued     = "queued"
+	WebhookStatusProcessing = "processing"
+	WebhookStatusProcessed  = "processed"
+	WebhookStatusIgnored    = "ignored"
+	WebhookStatusRejected   = "rejected"
+	WebhookStatusFailed     = "failed"
+	// WebhookStatusHeld waits for manual review (e.g. an unmapped provider status) and is requeued from the admin API
+	WebhookStatusHeld = "held"
+)
+
+// WebhookEvent is a raw inbound provider callback, stored before it is processed
+// Once parsed and verified it is queued and picked up by the webhook worker
+type WebhookEvent struct {
+	ID                 int64      `json:"id" db:"id"`
+	Provider           string     `json:"provider" db:"provider"`
+	Channel            string     `json:"channel" db:"channel"`
+	Route              string     `json:"route" db:"route"`
+	SourceIP           string     `json:"source_ip" db:"source_ip"`
+	Headers            string     `json:"headers" db:"headers"`
+	Body               string     `json:"body" db:"body"`
+	PartnerReference   *string    `json:"partner_reference" db:"partner_reference"`
+	VerificationStatus string     `json:"verification_status" db:"verification_status"`
+	ProcessingStatus   string     `json:"processing_status" db:"processing_status"`
+	ProcessingMessage  *string    `json:"processing_message" db:"processing_message"`
+	EventType          *string    `json:"event_type" db:"event_type"`
+	EventStatus        *string    `json:"event_status" db:"event_status"`
+	Amount             Money      `json:"amount" db:"amount"`
+	EventTime          *string    `json:"event_time" db:"event_time"`
+	Method             *string    `json:"method" db:"method"`
+	AmountPolicy       *string    `json:"amount_policy" db:"amount_policy"`
+	Attempts           int        `json:"attempts" db:"attempts"`
+	AvailableAt        *time.Time `json:"available_at" db:"available_at"`
+	LockedBy           *string    `json:"locked_by" db:"locked_by"`
+	LockedAt           *time.Time `json:"locked_at" db:"locked_at"`
+	CreatedAt          *time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt          *time.Time `json:"updated_at" db:"updated_at"`
+}
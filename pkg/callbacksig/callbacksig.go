@@ -0,0 +1,93 @@
+// Package callbacksig signs and verifies KytaPay merchant callbacks.
+//
+// Every callback carries two headers:
+//
+//	X-Timestamp: unix time in seconds when the callback was sent
+//	X-Signature: hex(HMAC-SHA256(secret, X-Timestamp + "." + raw request body))
+//
+// The package only depends on the standard library so merchants can vendor or copy it as is.
+//
+// Usage in a merchant's callback handler:
+//
+//	body, _ := io.ReadAll(r.Body)
+//	err := callbacksig.Verify(secret, r.Header.Get(callbacksig.HeaderTimestamp), body, r.Header.Get(callbacksig.HeaderSignature), 5*time.Minute)
+//	if err != nil {
+//		http.Error(w, "invalid signature", http.StatusUnauthorized)
+//		return
+//	}
+package callbacksig
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Header names used on signed callbacks
+const (
+	HeaderSignature = "X-Signature"
+	HeaderTimestamp = "X-Timestamp"
+)
+
+// DefaultTolerance is the recommended maximum age of a callback
+const DefaultTolerance = 5 * time.Minute
+
+var (
+	ErrMissingSignature = errors.New("callbacksig: missing signature or timestamp")
+	ErrInvalidTimestamp = errors.New("callbacksig: invalid timestamp")
+	ErrExpiredTimestamp = errors.New("callbacksig: timestamp outside tolerance")
+	ErrInvalidSignature = errors.New("callbacksig: signature mismatch")
+)
+
+// Sign returns the hex encoded HMAC-SHA256 of timestamp + "." + body
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Timestamp formats t the way it is sent in X-Timestamp
+func Timestamp(t time.Time) string {
+	return strconv.FormatInt(t.Unix(), 10)
+}
+
+// Verify checks signature against body and timestamp using a constant-time comparison
+// A tolerance of 0 disables the timestamp age check
+func Verify(secret, timestamp string, body []byte, signature string, tolerance time.Duration) error {
+	timestamp = strings.TrimSpace(timestamp)
+	signature = strings.TrimSpace(signature)
+	if timestamp == "" || signature == "" {
+		return ErrMissingSignature
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidTimestamp
+	}
+
+	if tolerance > 0 {
+		age := time.Since(time.Unix(unix, 0))
+		if age < -tolerance || age > tolerance {
+			return ErrExpiredTimestamp
+		}
+	}
+
+	expected, err := hex.DecodeString(Sign(secret, timestamp, body))
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	provided, err := hex.DecodeString(strings.ToLower(signature))
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if !hmac.Equal(expected, provided) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
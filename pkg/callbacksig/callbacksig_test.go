@@ -0,0 +1,123 @@
+package callbacksig
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSecret = "whsec_test"
+
+var testBody = []byte(`{"order_id":"KP-001","status":"Success"}`)
+
+func TestSignKnownVector(t *testing.T) {
+	// hex(HMAC-SHA256("whsec_test", "1760774400." + body)), computed independently
+	want := "5f02eda320e64592754f04af3b5d06905a80585633b2b71c02ff4d323c254b58"
+	if got := Sign(testSecret, "1760774400", testBody); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestSignVerifyRoundTrip(t *testing.T) {
+	timestamp := Timestamp(time.Now())
+	signature := Sign(testSecret, timestamp, testBody)
+
+	if err := Verify(testSecret, timestamp, testBody, signature, DefaultTolerance); err != nil {
+		t.Fatal(err)
+	}
+	// Upper case hex and surrounding whitespace are accepted
+	if err := Verify(testSecret, " "+timestamp+" ", testBody, " "+strings.ToUpper(signature)+" ", DefaultTolerance); err != nil {
+		t.Errorf("upper case signature: %v", err)
+	}
+}
+
+func TestVerifyRejects(t *testing.T) {
+	now := time.Now()
+	timestamp := Timestamp(now)
+	signature := Sign(testSecret, timestamp, testBody)
+
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp string
+		body      []byte
+		signature string
+		want      error
+	}{
+		{"tampered body", testSecret, timestamp, []byte(`{"order_id":"KP-001","status":"Failed"}`), signature, ErrInvalidSignature},
+		{"body with extra whitespace", testSecret, timestamp, []byte(`{"order_id": "KP-001","status":"Success"}`), signature, ErrInvalidSignature},
+		{"wrong secret", "whsec_other", timestamp, testBody, signature, ErrInvalidSignature},
+		{"timestamp swapped", testSecret, Timestamp(now.Add(-time.Second)), testBody, signature, ErrInvalidSignature},
+		{"signature not hex", testSecret, timestamp, testBody, "not-a-signature", ErrInvalidSignature},
+		{"missing signature", testSecret, timestamp, testBody, "", ErrMissingSignature},
+		{"missing timestamp", testSecret, "", testBody, signature, ErrMissingSignature},
+		{"timestamp not unix", testSecret, now.Format(time.RFC3339), testBody, signature, ErrInvalidTimestamp},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(tt.secret, tt.timestamp, tt.body, tt.signature, DefaultTolerance)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyTolerance(t *testing.T) {
+	tests := []struct {
+		name      string
+		sentAt    time.Time
+		tolerance time.Duration
+		want      error
+	}{
+		{"within tolerance", time.Now().Add(-4 * time.Minute), DefaultTolerance, nil},
+		{"too old", time.Now().Add(-6 * time.Minute), DefaultTolerance, ErrExpiredTimestamp},
+		{"too far in the future", time.Now().Add(6 * time.Minute), DefaultTolerance, ErrExpiredTimestamp},
+		{"check disabled", time.Now().Add(-24 * time.Hour), 0, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			timestamp := Timestamp(tt.sentAt)
+			err := Verify(testSecret, timestamp, testBody, Sign(testSecret, timestamp, testBody), tt.tolerance)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+// TestVerifyHeaderNameCase sends the headers with lower case names, as HTTP/2 and many proxies do,
+// and verifies them the way the package documentation tells merchants to
+func TestVerifyHeaderNameCase(t *testing.T) {
+	var verifyErr error
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		verifyErr = Verify(testSecret, r.Header.Get(HeaderTimestamp), body, r.Header.Get(HeaderSignature), DefaultTolerance)
+	}))
+	defer server.Close()
+
+	timestamp := Timestamp(time.Now())
+	req, err := http.NewRequest(http.MethodPost, server.URL, strings.NewReader(string(testBody)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Bypass canonicalization so the names go over the wire in lower case
+	req.Header["x-timestamp"] = []string{timestamp}
+	req.Header["x-signature"] = []string{Sign(testSecret, timestamp, testBody)}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if verifyErr != nil {
+		t.Errorf("lower case header names: %v", verifyErr)
+	}
+}
@@ -0,0 +1,220 @@
+// Package snap implements the inbound side of SNAP BI (Standar Nasional Open API Pembayaran Indonesia):
+// mandatory header validation, the transactional string-to-sign, symmetric / asymmetric signature
+// verification and SNAP response codes.
+//
+// A SNAP response code is HTTP status (3 digits) + service code (2 digits) + case code (2 digits),
+// e.g. 4012500 is "Unauthorized" for service 25 (virtual account payment).
+//
+// The package only depends on the standard library; partner credentials and the external id store
+// are provided by the caller.
+package snap
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Header names of a SNAP request
+const (
+	HeaderSignature  = "X-SIGNATURE"
+	HeaderTimestamp  = "X-TIMESTAMP"
+	HeaderPartnerID  = "X-PARTNER-ID"
+	HeaderExternalID = "X-EXTERNAL-ID"
+	HeaderChannelID  = "CHANNEL-ID"
+)
+
+// Service codes used in response codes
+const (
+	ServiceVAInquiry = "24"
+	ServiceVAPayment = "25"
+)
+
+// maxExternalIDLength is the SNAP limit for X-EXTERNAL-ID
+const maxExternalIDLength = 36
+
+// Headers are the validated mandatory headers of a SNAP request
+type Headers struct {
+	Signature  string
+	Timestamp  string
+	PartnerID  string
+	ExternalID string
+	ChannelID  string
+	// SentAt is X-TIMESTAMP parsed as ISO 8601
+	SentAt time.Time
+}
+
+// Error is a SNAP error response; the service code is added when the response is built
+type Error struct {
+	HTTPStatus int
+	CaseCode   string
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// ResponseCode returns the 7 digit SNAP response code for a service
+func (e *Error) ResponseCode(serviceCode string) string {
+	return fmt.Sprintf("%d%s%s", e.HTTPStatus, serviceCode, e.CaseCode)
+}
+
+// InvalidFieldFormat is returned for a header or field with an invalid format (case 01)
+func InvalidFieldFormat(field string) *Error {
+	return &Error{HTTPStatus: http.StatusBadRequest, CaseCode: "01", Message: "Invalid Field Format " + field}
+}
+
+// InvalidMandatoryField is returned for a missing header or field (case 02)
+func InvalidMandatoryField(field string) *Error {
+	return &Error{HTTPStatus: http.StatusBadRequest, CaseCode: "02", Message: "Invalid Mandatory Field " + field}
+}
+
+// Unauthorized is returned when the request cannot be authenticated (case 00)
+func Unauthorized(reason string) *Error {
+	return &Error{HTTPStatus: http.StatusUnauthorized, CaseCode: "00", Message: "Unauthorized. " + reason}
+}
+
+// Forbidden is returned when the request source is not allowed, e.g. an IP outside the allowlist (case 00)
+func Forbidden(reason string) *Error {
+	return &Error{HTTPStatus: http.StatusForbidden, CaseCode: "00", Message: "Forbidden. " + reason}
+}
+
+// BadRequest is returned when the request body cannot be used (case 00)
+func BadRequest() *Error {
+	return &Error{HTTPStatus: http.StatusBadRequest, CaseCode: "00", Message: "Bad Request"}
+}
+
+// Conflict is returned for a reused X-EXTERNAL-ID (case 00)
+func Conflict() *Error {
+	return &Error{HTTPStatus: http.StatusConflict, CaseCode: "00", Message: "Conflict"}
+}
+
+// BillNotFound is returned when no bill / virtual account matches the request (case 12)
+func BillNotFound() *Error {
+	return &Error{HTTPStatus: http.StatusNotFound, CaseCode: "12", Message: "Invalid Bill/Virtual Account [Not Found]"}
+}
+
+// PaidBill is returned when the bill / virtual account was already paid (case 14)
+func PaidBill() *Error {
+	return &Error{HTTPStatus: http.StatusNotFound, CaseCode: "14", Message: "Paid Bill"}
+}
+
+// ExpiredBill is returned when the bill / virtual account can no longer be paid (case 19)
+func ExpiredBill() *Error {
+	return &Error{HTTPStatus: http.StatusNotFound, CaseCode: "19", Message: "Invalid Bill/Virtual Account [Expired]"}
+}
+
+// GeneralError is returned when the request could not be processed and may be retried (case 00)
+func GeneralError() *Error {
+	return &Error{HTTPStatus: http.StatusInternalServerError, CaseCode: "00", Message: "General Error"}
+}
+
+// Response builds the {responseCode, responseMessage} body of a SNAP error
+func Response(err *Error, serviceCode string) (int, map[string]interface{}) {
+	return err.HTTPStatus, map[string]interface{}{
+		"responseCode":    err.ResponseCode(serviceCode),
+		"responseMessage": err.Message,
+	}
+}
+
+// SuccessResponse builds the body of a successful SNAP response; data is merged into the body
+func SuccessResponse(serviceCode string, data map[string]interface{}) (int, map[string]interface{}) {
+	body := map[string]interface{}{
+		"responseCode":    "200" + serviceCode + "00",
+		"responseMessage": "Successful",
+	}
+	for key, value := range data {
+		body[key] = value
+	}
+	return http.StatusOK, body
+}
+
+// ParseHeaders checks that every mandatory SNAP header is present and well formed
+func ParseHeaders(header http.Header) (*Headers, *Error) {
+	headers := &Headers{
+		Signature:  strings.TrimSpace(header.Get(HeaderSignature)),
+		Timestamp:  strings.TrimSpace(header.Get(HeaderTimestamp)),
+		PartnerID:  strings.TrimSpace(header.Get(HeaderPartnerID)),
+		ExternalID: strings.TrimSpace(header.Get(HeaderExternalID)),
+		ChannelID:  strings.TrimSpace(header.Get(HeaderChannelID)),
+	}
+
+	for _, field := range []struct{ name, value string }{
+		{HeaderSignature, headers.Signature},
+		{HeaderTimestamp, headers.Timestamp},
+		{HeaderPartnerID, headers.PartnerID},
+		{HeaderExternalID, headers.ExternalID},
+		{HeaderChannelID, headers.ChannelID},
+	} {
+		if field.value == "" {
+			return nil, InvalidMandatoryField(field.name)
+		}
+	}
+
+	sentAt, err := time.Parse(time.RFC3339, headers.Timestamp)
+	if err != nil {
+		return nil, InvalidFieldFormat(HeaderTimestamp)
+	}
+	headers.SentAt = sentAt
+
+	if len(headers.ExternalID) > maxExternalIDLength {
+		return nil, InvalidFieldFormat(HeaderExternalID)
+	}
+
+	return headers, nil
+}
+
+// ExternalIDDay returns the day X-EXTERNAL-ID has to be unique in: the X-TIMESTAMP date in loc
+func (h *Headers) ExternalIDDay(loc *time.Location) string {
+	return h.SentAt.In(loc).Format("2006-01-02")
+}
+
+// Minify compacts a JSON body without reordering its keys
+func Minify(body []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, body); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// StringToSign builds the transactional string-to-sign from an already minified body:
+// <HTTP METHOD> + ":" + <PATH> + ":" + LowerCase(HexEncode(SHA-256(<minified body>))) + ":" + <X-TIMESTAMP>
+func StringToSign(method, path string, minifiedBody []byte, timestamp string) string {
+	hash := sha256.Sum256(minifiedBody)
+	return fmt.Sprintf("%s:%s:%s:%s", method, path, strings.ToLower(hex.EncodeToString(hash[:])), timestamp)
+}
+
+// VerifySymmetric verifies a base64 HMAC-SHA512 signature in constant time
+func VerifySymmetric(stringToSign, signature, secret string) bool {
+	mac := hmac.New(sha512.New, []byte(secret))
+	mac.Write([]byte(stringToSign))
+
+	signatureBytes, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(signatureBytes, mac.Sum(nil))
+}
+
+// VerifyAsymmetric verifies a base64 SHA256withRSA (PKCS#1 v1.5) signature
+func VerifyAsymmetric(stringToSign, signature string, publicKey *rsa.PublicKey) bool {
+	signatureBytes, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+
+	hashed := sha256.Sum256([]byte(stringToSign))
+	return rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, hashed[:], signatureBytes) == nil
+}
@@ -0,0 +1,94 @@
+package providers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/kytapay/webhook-v2/config"
+	"github.com/kytapay/webhook-v2/helpers"
+)
+
+// LinkQu handles LinkQu callbacks for one channel
+// LinkQu authenticates with client-id / client-secret headers and expects 200 for every stored callback
+type LinkQu struct {
+	channel Channel
+}
+
+func NewLinkQu(channel Channel) *LinkQu {
+	return &LinkQu{channel: channel}
+}
+
+func (p *LinkQu) Name() string {
+	return "LinkQu"
+}
+
+func (p *LinkQu) Channel() Channel {
+	return p.channel
+}
+
+func (p *LinkQu) AllowedIPs() []string {
+	return config.GetLinkQuConfig().AllowedIPs
+}
+
+// Verify checks client-id and client-secret against the active LinkQu credentials
+func (p *LinkQu) Verify(req *Request) (*Verification, error) {
+	clientID := req.Header.Get("client-id")
+	if !helpers.VerifyLinkQuSignature(clientID, req.Header.Get("client-secret")) {
+		return nil, fmt.Errorf("invalid client credentials (client-id: %s)", clientID)
+	}
+	return &Verification{Method: "client_credentials"}, nil
+}
+
+// Parse reads partner_reff, status, amount and transaction_time; type=SETTLE marks a settlement
+func (p *LinkQu) Parse(req *Request) (*Event, error) {
+	var data map[string]interface{}
+	if err := helpers.DecodeJSON(req.Body, &data); err != nil {
+		return nil, fmt.Errorf("%w: invalid JSON body", ErrInvalidPayload)
+	}
+
+	partnerRef, _ := data["partner_reff"].(string)
+	status, _ := data["status"].(string)
+	transactionTime, _ := data["transaction_time"].(string)
+	callbackType, _ := data["type"].(string)
+
+	if partnerRef == "" {
+		return nil, ErrMissingReference
+	}
+
+	amount, err := parseAmount("amount", data["amount"])
+	if err != nil {
+		return nil, err
+	}
+
+	if p.channel.Payout {
+		return &Event{Payout: &PayoutEvent{
+			PartnerReference: partnerRef,
+			Status:           status,
+			Amount:           amount,
+			EventTime:        transactionTime,
+			Method:           p.channel.Method,
+		}}, nil
+	}
+
+	return &Event{Payment: &PaymentEvent{
+		PartnerReference: partnerRef,
+		Status:           status,
+		Amount:           amount,
+		EventTime:        transactionTime,
+		Method:           p.channel.Method,
+		Settlement:       strings.ToUpper(callbackType) == "SETTLE",
+	}}, nil
+}
+
+// Ack always answers 200 so LinkQu stops resending, except when the callback was not stored
+func (p *LinkQu) Ack(outcome, message string) (int, interface{}) {
+	switch outcome {
+	case AckForbidden:
+		return snapAck(http.StatusForbidden, "4032800", "Forbidden. Source IP not allowed")
+	case AckRetryLater:
+		return snapAck(http.StatusInternalServerError, "5002800", "Internal Server Error")
+	default:
+		return snapAck(http.StatusOK, "2002800", "Successful")
+	}
+}
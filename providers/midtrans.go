@@ -0,0 +1,176 @@
+package providers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/kytapay/webhook-v2/config"
+	"github.com/kytapay/webhook-v2/helpers"
+)
+
+// ChannelMidtrans receives Midtrans HTTP notifications; the payment method is taken from payment_type
+var ChannelMidtrans = Channel{Code: "NOTIFICATION", Label: "Notification"}
+
+// midtransPaymentTypes maps Midtrans payment_type values to pipeline payment methods
+var midtransPaymentTypes = map[string]string{
+	"bank_transfer": "VA",
+	"echannel":      "VA",
+	"permata":       "VA",
+	"qris":          "QRIS",
+	"gopay":         "EWALLET",
+	"shopeepay":     "EWALLET",
+}
+
+// midtransCaptureTypes are charged in two steps: capture, then settlement once Midtrans settles the funds.
+// Their settlement notification is a settlement event; for other payment types settlement is the payment itself
+var midtransCaptureTypes = map[string]bool{
+	"credit_card": true,
+}
+
+// midtransNotification holds the notification fields used for verification and parsing
+type midtransNotification struct {
+	OrderID           string      `json:"order_id"`
+	StatusCode        string      `json:"status_code"`
+	GrossAmount       json.Number `json:"gross_amount"`
+	SignatureKey      string      `json:"signature_key"`
+	TransactionStatus string      `json:"transaction_status"`
+	FraudStatus       string      `json:"fraud_status"`
+	PaymentType       string      `json:"payment_type"`
+	TransactionTime   string      `json:"transaction_time"`
+	SettlementTime    string      `json:"settlement_time"`
+}
+
+// Midtrans handles Midtrans HTTP notifications; order_id is the gateway_reference of the merchant payment
+type Midtrans struct {
+	channel Channel
+}
+
+func NewMidtrans() *Midtrans {
+	return &Midtrans{channel: ChannelMidtrans}
+}
+
+func (p *Midtrans) Name() string {
+	return "Midtrans"
+}
+
+func (p *Midtrans) Channel() Channel {
+	return p.channel
+}
+
+func (p *Midtrans) AllowedIPs() []string {
+	return config.GetMidtransConfig().AllowedIPs
+}
+
+func (p *Midtrans) Verify(req *Request) (*Verification, error) {
+	notification, err := decodeMidtransNotification(req.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if !helpers.VerifyMidtransSignature(notification.OrderID, notification.StatusCode, notification.GrossAmount.String(), notification.SignatureKey) {
+		return nil, errors.New("invalid signature_key")
+	}
+
+	// capture and settlement of one order share the same signature_key, so the status is part of the replay key
+	return &Verification{
+		Method:    "signature_key",
+		ReplayKey: notification.TransactionStatus + ":" + notification.SignatureKey,
+	}, nil
+}
+
+func (p *Midtrans) Parse(req *Request) (*Event, error) {
+	notification, err := decodeMidtransNotification(req.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if notification.OrderID == "" {
+		return nil, ErrMissingReference
+	}
+
+	status, err := midtransStatus(notification.TransactionStatus, notification.FraudStatus)
+	if err != nil {
+		return nil, err
+	}
+
+	method, ok := midtransPaymentTypes[strings.ToLower(notification.PaymentType)]
+	if !ok {
+		method = strings.ToUpper(notification.PaymentType)
+	}
+
+	amount, err := parseAmount("gross_amount", notification.GrossAmount)
+	if err != nil {
+		return nil, err
+	}
+
+	eventTime := notification.SettlementTime
+	if eventTime == "" {
+		eventTime = notification.TransactionTime
+	}
+
+	settlement := strings.EqualFold(notification.TransactionStatus, "settlement") && midtransCaptureTypes[strings.ToLower(notification.PaymentType)]
+
+	return &Event{Payment: &PaymentEvent{
+		PartnerReference: notification.OrderID,
+		Status:           status,
+		Amount:           amount,
+		EventTime:        eventTime,
+		Method:           method,
+		Settlement:       settlement,
+	}}, nil
+}
+
+// midtransStatus translates transaction_status + fraud_status into a status of the MIDTRANS status mapping
+// A capture is only final when fraud_status is accept; challenge waits for the merchant decision
+// Other statuses are passed through to the status mapping: refund maps to refunded and reverses the payment,
+// anything unmapped (partial_refund, chargeback, ...) is held for review
+func midtransStatus(transactionStatus, fraudStatus string) (string, error) {
+	switch strings.ToLower(transactionStatus) {
+	case "capture":
+		switch strings.ToLower(fraudStatus) {
+		case "", "accept":
+			return "CAPTURED", nil
+		case "challenge":
+			return "PENDING", nil
+		default:
+			return "FAILED", nil
+		}
+	case "settlement":
+		return "SUCCESS", nil
+	case "pending", "authorize":
+		return "PENDING", nil
+	case "deny", "cancel", "failure":
+		return "FAILED", nil
+	case "expire":
+		return "EXPIRED", nil
+	case "":
+		return "", fmt.Errorf("%w: missing transaction_status", ErrInvalidPayload)
+	default:
+		return strings.ToUpper(transactionStatus), nil
+	}
+}
+
+func decodeMidtransNotification(body []byte) (*midtransNotification, error) {
+	var notification midtransNotification
+	if err := helpers.DecodeJSON(body, &notification); err != nil {
+		return nil, fmt.Errorf("%w: invalid JSON body", ErrInvalidPayload)
+	}
+	return &notification, nil
+}
+
+// Ack answers 200 for stored or dropped notifications; any other status makes Midtrans retry
+func (p *Midtrans) Ack(outcome, message string) (int, interface{}) {
+	switch outcome {
+	case AckUnauthorized:
+		return http.StatusUnauthorized, map[string]string{"status": "error", "message": message}
+	case AckForbidden:
+		return http.StatusForbidden, map[string]string{"status": "error", "message": "Source IP not allowed"}
+	case AckRetryLater:
+		return http.StatusInternalServerError, map[string]string{"status": "error", "message": "Notification could not be stored, please retry"}
+	default:
+		return http.StatusOK, map[string]string{"status": "ok"}
+	}
+}
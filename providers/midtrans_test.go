@@ -0,0 +1,89 @@
+package providers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kytapay/webhook-v2/models"
+)
+
+// midtransRequest builds a request from a fixture in testdata/midtrans
+func midtransRequest(t *testing.T, fixture string) *Request {
+	t.Helper()
+
+	body, err := os.ReadFile(filepath.Join("testdata", "midtrans", fixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Request{Method: "POST", Body: body}
+}
+
+func TestMidtransParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		fixture string
+		want    PaymentEvent
+	}{
+		{
+			name:    "card capture",
+			fixture: "card_capture.json",
+			want: PaymentEvent{
+				PartnerReference: "KP-CC-20261018-0201",
+				Status:           "CAPTURED",
+				Amount:           models.Rupiah(250000),
+				EventTime:        "2026-10-18 14:02:11",
+				Method:           "CREDIT_CARD",
+			},
+		},
+		{
+			name:    "card capture challenged by fraud detection",
+			fixture: "card_challenge.json",
+			want: PaymentEvent{
+				PartnerReference: "KP-CC-20261018-0202",
+				Status:           "PENDING",
+				Amount:           models.Rupiah(99000),
+				EventTime:        "2026-10-18 14:05:40",
+				Method:           "CREDIT_CARD",
+			},
+		},
+		{
+			name:    "card settlement settles the capture",
+			fixture: "card_settlement.json",
+			want: PaymentEvent{
+				PartnerReference: "KP-CC-20261018-0201",
+				Status:           "SUCCESS",
+				Amount:           models.Rupiah(250000),
+				EventTime:        "2026-10-19 09:15:02",
+				Method:           "CREDIT_CARD",
+				Settlement:       true,
+			},
+		},
+		{
+			name:    "VA settlement is the payment",
+			fixture: "va_settlement.json",
+			want: PaymentEvent{
+				PartnerReference: "KP-VA-20261018-0203",
+				Status:           "SUCCESS",
+				Amount:           models.Rupiah(125000),
+				EventTime:        "2026-10-18 15:21:44",
+				Method:           "VA",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event, err := NewMidtrans().Parse(midtransRequest(t, tt.fixture))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if event.Payment == nil {
+				t.Fatalf("expected a payment event, got %+v", event)
+			}
+			if *event.Payment != tt.want {
+				t.Errorf("got %+v, want %+v", *event.Payment, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package providers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/kytapay/webhook-v2/config"
+	"github.com/kytapay/webhook-v2/helpers"
+	"github.com/kytapay/webhook-v2/models"
+)
+
+// PakaiLink handles PakaiLink SNAP callbacks for one channel
+// X-SIGNATURE is verified when sent, otherwise the callback token or IP allowlist (see helpers.VerifyPakaiLinkCallback)
+type PakaiLink struct {
+	channel Channel
+}
+
+func NewPakaiLink(channel Channel) *PakaiLink {
+	return &PakaiLink{channel: channel}
+}
+
+func (p *PakaiLink) Name() string {
+	return "PakaiLink"
+}
+
+func (p *PakaiLink) Channel() Channel {
+	return p.channel
+}
+
+func (p *PakaiLink) AllowedIPs() []string {
+	return config.GetPakaiLinkConfig().AllowedIPs
+}
+
+func (p *PakaiLink) Verify(req *Request) (*Verification, error) {
+	method, err := helpers.VerifyPakaiLinkCallback(req.Method, req.Path, string(req.Body), req.Header, req.ClientIP)
+	if err != nil {
+		if method != "" {
+			err = fmt.Errorf("%s: %w", method, err)
+		}
+		return nil, err
+	}
+
+	verification := &Verification{Method: method}
+	if method == helpers.PakaiLinkAuthSignature {
+		verification.ReplayKey = req.Header.Get("X-SIGNATURE")
+	}
+	return verification, nil
+}
+
+// Parse reads transactionData; the raw paymentFlagStatus is mapped per channel by the status mapping
+// and callbackType=settlement marks a settlement
+// PakaiLink sends no event time, the current time is used instead
+func (p *PakaiLink) Parse(req *Request) (*Event, error) {
+	var requestData map[string]interface{}
+	if err := helpers.DecodeJSON(req.Body, &requestData); err != nil {
+		return nil, fmt.Errorf("%w: invalid JSON body", ErrInvalidPayload)
+	}
+
+	transactionData, ok := requestData["transactionData"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: missing transactionData", ErrInvalidPayload)
+	}
+
+	partnerRef, _ := transactionData["partnerReferenceNo"].(string)
+	paymentFlagStatus, _ := transactionData["paymentFlagStatus"].(string)
+	callbackType, _ := transactionData["callbackType"].(string)
+	paidAmount, _ := transactionData["paidAmount"].(map[string]interface{})
+
+	if partnerRef == "" {
+		return nil, ErrMissingReference
+	}
+
+	var amount models.Money
+	if paidAmount != nil {
+		var err error
+		if amount, err = parseAmount("paidAmount.value", paidAmount["value"]); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.channel.Payout {
+		return &Event{Payout: &PayoutEvent{
+			PartnerReference: partnerRef,
+			Status:           paymentFlagStatus,
+			Amount:           amount,
+			EventTime:        jakartaNow(),
+			Method:           p.channel.Method,
+		}}, nil
+	}
+
+	return &Event{Payment: &PaymentEvent{
+		PartnerReference: partnerRef,
+		Status:           paymentFlagStatus,
+		Amount:           amount,
+		EventTime:        jakartaNow(),
+		Method:           p.channel.Method,
+		Settlement:       strings.ToLower(callbackType) == "settlement",
+	}}, nil
+}
+
+func (p *PakaiLink) Ack(outcome, message string) (int, interface{}) {
+	switch outcome {
+	case AckUnauthorized:
+		return snapAck(http.StatusUnauthorized, "4012800", "Unauthorized. "+message)
+	case AckForbidden:
+		return snapAck(http.StatusForbidden, "4032800", "Forbidden. Source IP not allowed")
+	case AckReplayed:
+		return snapAck(http.StatusConflict, "4092800", "Conflict. Callback already received")
+	case AckRetryLater:
+		return snapAck(http.StatusInternalServerError, "5002800", "Internal Server Error")
+	default:
+		return snapAck(http.StatusOK, "2002800", "Successful")
+	}
+}
@@ -0,0 +1,107 @@
+package providers
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kytapay/webhook-v2/helpers"
+	"github.com/kytapay/webhook-v2/models"
+)
+
+// pakaiLinkRequest builds a request from a fixture in testdata/pakailink
+func pakaiLinkRequest(t *testing.T, fixture string) *Request {
+	t.Helper()
+
+	body, err := os.ReadFile(filepath.Join("testdata", "pakailink", fixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Request{Method: "POST", Body: body}
+}
+
+func TestPakaiLinkParsePassesRawFlag(t *testing.T) {
+	tests := []struct {
+		channel    Channel
+		fixture    string
+		reference  string
+		status     string
+		amount     models.Money
+		settlement bool
+	}{
+		{ChannelVA, "va_payment.json", "KP-VA-20261018-0101", "00", models.Rupiah(75000), false},
+		{ChannelVA, "va_settlement.json", "KP-VA-20261018-0101", "00", models.Rupiah(75000), true},
+		{ChannelPayoutBank, "payout_pending.json", "KP-PO-20261018-0102", "03", models.Rupiah(150000), false},
+		{ChannelPayoutEWallet, "payout_unknown.json", "KP-PO-20261018-0103", "07", models.Rupiah(150000), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.channel.Code+" "+tt.fixture, func(t *testing.T) {
+			event, err := NewPakaiLink(tt.channel).Parse(pakaiLinkRequest(t, tt.fixture))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var reference, status string
+			var amount models.Money
+			settlement := false
+			if tt.channel.Payout {
+				if event.Payout == nil {
+					t.Fatalf("expected a payout event, got %+v", event)
+				}
+				reference, status, amount = event.Payout.PartnerReference, event.Payout.Status, event.Payout.Amount
+			} else {
+				if event.Payment == nil {
+					t.Fatalf("expected a payment event, got %+v", event)
+				}
+				reference, status, amount = event.Payment.PartnerReference, event.Payment.Status, event.Payment.Amount
+				settlement = event.Payment.Settlement
+			}
+
+			if reference != tt.reference || status != tt.status || amount != tt.amount || settlement != tt.settlement {
+				t.Errorf("got reference %s status %q amount %s settlement %v, want %s %q %s %v",
+					reference, status, amount, settlement, tt.reference, tt.status, tt.amount, tt.settlement)
+			}
+		})
+	}
+}
+
+func TestPakaiLinkFlagMapping(t *testing.T) {
+	tests := []struct {
+		channel Channel
+		flag    string
+		review  bool
+		status  string
+	}{
+		{ChannelVA, "00", false, models.LifecycleSuccess},
+		{ChannelVA, "01", false, models.LifecyclePending},
+		{ChannelVA, "06", true, ""},
+		{ChannelPayoutBank, "00", false, models.LifecycleSuccess},
+		{ChannelPayoutBank, "03", false, models.LifecyclePending},
+		{ChannelPayoutBank, "06", false, models.LifecycleFailed},
+		{ChannelPayoutBank, "04", true, ""},
+		{ChannelPayoutEWallet, "07", true, ""},
+		{ChannelPayoutEWallet, "", true, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.channel.Code+" "+tt.flag, func(t *testing.T) {
+			outcome, mapped := helpers.MapStatus("PakaiLink", tt.channel.Code, tt.flag)
+			if outcome.Review() != tt.review || mapped == tt.review {
+				t.Fatalf("flag %q: review = %v, mapped = %v, want review %v", tt.flag, outcome.Review(), mapped, tt.review)
+			}
+			if !tt.review && outcome.MerchantStatus() != tt.status {
+				t.Errorf("flag %q: status = %s, want %s", tt.flag, outcome.MerchantStatus(), tt.status)
+			}
+		})
+	}
+}
+
+func TestPakaiLinkParseRejectsMalformedAmount(t *testing.T) {
+	body := []byte(`{"transactionData": {"partnerReferenceNo": "KP-VA-20261018-0104", "paymentFlagStatus": "00", "paidAmount": {"value": "75.000,00", "currency": "IDR"}}}`)
+	_, err := NewPakaiLink(ChannelVA).Parse(&Request{Method: "POST", Body: body})
+	if !errors.Is(err, ErrInvalidAmount) || !errors.Is(err, ErrInvalidPayload) {
+		t.Fatalf("expected ErrInvalidAmount, got %v", err)
+	}
+}
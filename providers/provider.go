@@ -0,0 +1,169 @@
+package providers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/kytapay/webhook-v2/helpers"
+	"github.com/kytapay/webhook-v2/models"
+)
+
+var (
+	// ErrInvalidPayload is wrapped by Parse when the body cannot be used; the callback is acknowledged and dropped
+	ErrInvalidPayload = errors.New("invalid payload")
+	// ErrMissingReference is wrapped by Parse when the callback carries no partner reference
+	ErrMissingReference = errors.New("missing partner reference")
+	// ErrInvalidAmount is wrapped by Parse when an amount field is malformed; it also wraps ErrInvalidPayload
+	ErrInvalidAmount = fmt.Errorf("%w: invalid amount", ErrInvalidPayload)
+)
+
+// parseAmount reads an amount field of a decoded callback; a malformed amount fails the parse
+func parseAmount(field string, value interface{}) (models.Money, error) {
+	amount, err := helpers.ParseAmount(value)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s: %v", ErrInvalidAmount, field, err)
+	}
+	return amount, nil
+}
+
+// Request is an inbound provider callback
+type Request struct {
+	Method   string
+	Path     string
+	Header   http.Header
+	Body     []byte
+	ClientIP string
+}
+
+// Verification describes how a callback was authenticated
+type Verification struct {
+	Method string
+	// ReplayKey is the signature used to detect replays; empty for unsigned callbacks
+	ReplayKey string
+	// ExternalID must be unique per provider per day (SNAP X-EXTERNAL-ID); nil when the provider has none
+	ExternalID *ExternalID
+}
+
+// ExternalID is a request id the provider may only use once per day
+type ExternalID struct {
+	// Day is the request date, YYYY-MM-DD
+	Day string
+	ID  string
+}
+
+// PaymentEvent is the canonical form of a payment or settlement callback
+type PaymentEvent struct {
+	PartnerReference string
+	Status           string
+	Amount           models.Money
+	EventTime        string
+	Method           string
+	Settlement       bool
+}
+
+// PayoutEvent is the canonical form of a payout callback
+type PayoutEvent struct {
+	PartnerReference string
+	Status           string
+	Amount           models.Money
+	EventTime        string
+	Method           string
+}
+
+// Event is the result of Parse; exactly one of Payment and Payout is set
+type Event struct {
+	Payment *PaymentEvent
+	Payout  *PayoutEvent
+}
+
+// PartnerReference returns the reference of whichever event is set
+func (e *Event) PartnerReference() string {
+	if e.Payout != nil {
+		return e.Payout.PartnerReference
+	}
+	if e.Payment != nil {
+		return e.Payment.PartnerReference
+	}
+	return ""
+}
+
+// Ack outcomes a provider has to answer
+const (
+	AckAccepted     = "accepted"
+	AckInvalid      = "invalid"
+	AckUnauthorized = "unauthorized"
+	AckReplayed     = "replayed"
+	AckRetryLater   = "retry_later"
+	AckForbidden    = "forbidden"
+)
+
+// Provider adapts one provider channel to the webhook pipeline
+type Provider interface {
+	// Name is the provider name stored in webhook_events.provider
+	Name() string
+	// Channel is the callback channel handled by this adapter
+	Channel() Channel
+	// AllowedIPs is the current source IP allowlist (<PROVIDER>_ALLOWED_IPS); empty allows every address
+	AllowedIPs() []string
+	// Verify authenticates the callback
+	Verify(req *Request) (*Verification, error)
+	// Parse converts the callback into a canonical event
+	Parse(req *Request) (*Event, error)
+	// Ack returns the HTTP status and body for an outcome
+	Ack(outcome, message string) (int, interface{})
+}
+
+// ErrorAcker is implemented by providers whose response depends on the error, e.g. SNAP response codes
+// The handler uses AckError instead of Ack whenever an outcome has an error
+type ErrorAcker interface {
+	AckError(outcome string, err error) (int, interface{})
+}
+
+// Channel describes a callback route
+type Channel struct {
+	// Code is stored in webhook_events.channel
+	Code string
+	// Method is the payment method passed to the settlement pipeline
+	Method string
+	// Label names the channel in alerts
+	Label  string
+	Payout bool
+}
+
+var (
+	ChannelQRIS          = Channel{Code: "QRIS", Method: "QRIS", Label: "QRIS"}
+	ChannelEWallet       = Channel{Code: "EWALLET", Method: "EWALLET", Label: "E-Wallet"}
+	ChannelVA            = Channel{Code: "VA", Method: "VA", Label: "VA"}
+	ChannelPayoutBank    = Channel{Code: "PAYOUT_BANK", Method: "Bank", Label: "Bank Payout", Payout: true}
+	ChannelPayoutEWallet = Channel{Code: "PAYOUT_EWALLET", Method: "E-Wallet", Label: "E-Wallet Payout", Payout: true}
+)
+
+// Source names a provider channel in alerts, e.g. "QRIS LinkQu"
+func Source(p Provider) string {
+	return p.Channel().Label + " " + p.Name()
+}
+
+// jakartaNow returns the current time in Asia/Jakarta formatted as ISO 8601,
+// used when a provider does not send the event time
+func jakartaNow() string {
+	return time.Now().In(jakartaLocation()).Format("2006-01-02T15:04:05Z07:00")
+}
+
+// jakartaLocation returns Asia/Jakarta, or UTC when the timezone database is unavailable
+func jakartaLocation() *time.Location {
+	loc, err := time.LoadLocation("Asia/Jakarta")
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// snapAck builds the SNAP style {responseCode, responseMessage} body used by LinkQu and PakaiLink
+func snapAck(status int, code, message string) (int, interface{}) {
+	return status, map[string]string{
+		"responseCode":    code,
+		"responseMessage": message,
+	}
+}
@@ -0,0 +1,115 @@
+package providers
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/kytapay/webhook-v2/config"
+	"github.com/kytapay/webhook-v2/helpers"
+	"github.com/kytapay/webhook-v2/pkg/snap"
+)
+
+// SnapVA handles SNAP BI virtual account payment notifications (POST /v1.0/transfer-va/payment)
+// from one partner configured in SNAP_PARTNERS; trxId is the gateway_reference of the merchant payment
+type SnapVA struct {
+	partner string
+	channel Channel
+}
+
+func NewSnapVA(partner string) *SnapVA {
+	return &SnapVA{partner: partner, channel: ChannelVA}
+}
+
+func (p *SnapVA) Name() string {
+	return p.partner
+}
+
+func (p *SnapVA) Channel() Channel {
+	return p.channel
+}
+
+func (p *SnapVA) AllowedIPs() []string {
+	if partner := config.GetSnapPartner(p.partner); partner != nil {
+		return partner.AllowedIPs
+	}
+	return nil
+}
+
+func (p *SnapVA) Verify(req *Request) (*Verification, error) {
+	headers, err := helpers.VerifySnapRequest(p.partner, req.Method, req.Path, req.Body, req.Header)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Verification{
+		Method:    "snap_signature",
+		ReplayKey: headers.Signature,
+		ExternalID: &ExternalID{
+			Day: headers.ExternalIDDay(jakartaLocation()),
+			ID:  headers.ExternalID,
+		},
+	}, nil
+}
+
+// Parse reads the payment notification; the partner only notifies paid virtual accounts
+func (p *SnapVA) Parse(req *Request) (*Event, error) {
+	var requestData map[string]interface{}
+	if err := helpers.DecodeJSON(req.Body, &requestData); err != nil {
+		return nil, fmt.Errorf("%w: invalid JSON body", ErrInvalidPayload)
+	}
+
+	trxID, _ := requestData["trxId"].(string)
+	trxDateTime, _ := requestData["trxDateTime"].(string)
+	paidAmount, _ := requestData["paidAmount"].(map[string]interface{})
+	if trxID == "" {
+		return nil, fmt.Errorf("%w: trxId", ErrMissingReference)
+	}
+	if paidAmount == nil {
+		return nil, fmt.Errorf("%w: missing paidAmount", ErrInvalidPayload)
+	}
+	amount, err := parseAmount("paidAmount.value", paidAmount["value"])
+	if err != nil {
+		return nil, err
+	}
+	if trxDateTime == "" {
+		trxDateTime = jakartaNow()
+	}
+
+	return &Event{Payment: &PaymentEvent{
+		PartnerReference: trxID,
+		Status:           "SUCCESS",
+		Amount:           amount,
+		EventTime:        trxDateTime,
+		Method:           p.channel.Method,
+	}}, nil
+}
+
+func (p *SnapVA) Ack(outcome, message string) (int, interface{}) {
+	return p.AckError(outcome, errors.New(message))
+}
+
+// AckError answers with the SNAP response code of the error, or the standard code of the outcome
+func (p *SnapVA) AckError(outcome string, err error) (int, interface{}) {
+	var snapErr *snap.Error
+	if errors.As(err, &snapErr) {
+		return snap.Response(snapErr, snap.ServiceVAPayment)
+	}
+
+	switch outcome {
+	case AckAccepted:
+		return snap.SuccessResponse(snap.ServiceVAPayment, nil)
+	case AckUnauthorized:
+		return snap.Response(snap.Unauthorized(err.Error()), snap.ServiceVAPayment)
+	case AckForbidden:
+		return snap.Response(snap.Forbidden(err.Error()), snap.ServiceVAPayment)
+	case AckReplayed:
+		return snap.Response(snap.Conflict(), snap.ServiceVAPayment)
+	case AckInvalid:
+		if errors.Is(err, ErrMissingReference) {
+			return snap.Response(snap.InvalidMandatoryField("trxId"), snap.ServiceVAPayment)
+		}
+		return snap.Response(snap.BadRequest(), snap.ServiceVAPayment)
+	default:
+		return snap.Response(snap.GeneralError(), snap.ServiceVAPayment)
+	}
+}
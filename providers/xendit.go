@@ -0,0 +1,215 @@
+package providers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/kytapay/webhook-v2/config"
+	"github.com/kytapay/webhook-v2/helpers"
+)
+
+// ChannelInvoice receives Xendit invoice callbacks; the payment method is taken from the payload
+var ChannelInvoice = Channel{Code: "INVOICE", Label: "Invoice"}
+
+// xenditInvoiceMethods maps Xendit invoice payment_method values to pipeline payment methods
+var xenditInvoiceMethods = map[string]string{
+	"BANK_TRANSFER": "VA",
+	"EWALLET":       "EWALLET",
+	"QR_CODE":       "QRIS",
+}
+
+// Xendit handles Xendit callbacks for one channel: invoice, fixed VA payment, QR payment or disbursement
+// Xendit authenticates with the x-callback-token header; the object's external_id (reference_id for QR)
+// is the gateway_reference of the merchant payment / payout
+type Xendit struct {
+	channel Channel
+}
+
+func NewXendit(channel Channel) *Xendit {
+	return &Xendit{channel: channel}
+}
+
+func (p *Xendit) Name() string {
+	return "Xendit"
+}
+
+func (p *Xendit) Channel() Channel {
+	return p.channel
+}
+
+func (p *Xendit) AllowedIPs() []string {
+	return config.GetXenditConfig().AllowedIPs
+}
+
+func (p *Xendit) Verify(req *Request) (*Verification, error) {
+	if !helpers.VerifyXenditCallbackToken(req.Header.Get("x-callback-token")) {
+		return nil, errors.New("invalid x-callback-token")
+	}
+	return &Verification{Method: "callback_token"}, nil
+}
+
+func (p *Xendit) Parse(req *Request) (*Event, error) {
+	var data map[string]interface{}
+	if err := helpers.DecodeJSON(req.Body, &data); err != nil {
+		return nil, fmt.Errorf("%w: invalid JSON body", ErrInvalidPayload)
+	}
+
+	switch p.channel.Code {
+	case ChannelInvoice.Code:
+		return p.parseInvoice(data)
+	case ChannelVA.Code:
+		return p.parseVirtualAccount(data)
+	case ChannelQRIS.Code:
+		return p.parseQRPayment(data)
+	case ChannelPayoutBank.Code:
+		return p.parseDisbursement(data)
+	default:
+		return nil, fmt.Errorf("%w: unsupported Xendit channel %s", ErrInvalidPayload, p.channel.Code)
+	}
+}
+
+// parseInvoice reads an invoice callback; SETTLED arrives after PAID once the funds are settled
+func (p *Xendit) parseInvoice(data map[string]interface{}) (*Event, error) {
+	externalID, _ := data["external_id"].(string)
+	status, _ := data["status"].(string)
+	paymentMethod, _ := data["payment_method"].(string)
+	paidAt, _ := data["paid_at"].(string)
+	if paidAt == "" {
+		paidAt, _ = data["updated"].(string)
+	}
+
+	if externalID == "" {
+		return nil, ErrMissingReference
+	}
+
+	amount, err := parseAmount("paid_amount", data["paid_amount"])
+	if err != nil {
+		return nil, err
+	}
+	if amount == 0 {
+		if amount, err = parseAmount("amount", data["amount"]); err != nil {
+			return nil, err
+		}
+	}
+
+	method, ok := xenditInvoiceMethods[strings.ToUpper(paymentMethod)]
+	if !ok {
+		method = strings.ToUpper(paymentMethod)
+	}
+
+	settlement := strings.ToUpper(status) == "SETTLED"
+	if settlement {
+		status = "PAID"
+	}
+
+	return &Event{Payment: &PaymentEvent{
+		PartnerReference: externalID,
+		Status:           status,
+		Amount:           amount,
+		EventTime:        paidAt,
+		Method:           method,
+		Settlement:       settlement,
+	}}, nil
+}
+
+// parseVirtualAccount reads a fixed VA payment callback, which only exists for paid VAs
+// VA created/updated callbacks (no payment_id) are not payments and are dropped
+func (p *Xendit) parseVirtualAccount(data map[string]interface{}) (*Event, error) {
+	if _, ok := data["payment_id"]; !ok {
+		return nil, fmt.Errorf("%w: virtual account update without payment_id", ErrInvalidPayload)
+	}
+
+	externalID, _ := data["external_id"].(string)
+	transactionTime, _ := data["transaction_timestamp"].(string)
+	if externalID == "" {
+		return nil, ErrMissingReference
+	}
+	amount, err := parseAmount("amount", data["amount"])
+	if err != nil {
+		return nil, err
+	}
+
+	return &Event{Payment: &PaymentEvent{
+		PartnerReference: externalID,
+		Status:           "PAID",
+		Amount:           amount,
+		EventTime:        transactionTime,
+		Method:           p.channel.Method,
+	}}, nil
+}
+
+// parseQRPayment reads a qr.payment callback in both the current ({event, data: {reference_id, ...}})
+// and the legacy ({event, status, amount, qr_code: {external_id}}) shape
+func (p *Xendit) parseQRPayment(data map[string]interface{}) (*Event, error) {
+	if event, _ := data["event"].(string); event != "" && event != "qr.payment" {
+		return nil, fmt.Errorf("%w: unsupported QR event %s", ErrInvalidPayload, event)
+	}
+
+	var reference, status, created string
+	var payload map[string]interface{}
+	if inner, ok := data["data"].(map[string]interface{}); ok {
+		payload = inner
+		reference, _ = inner["reference_id"].(string)
+	} else {
+		payload = data
+		if qrCode, ok := data["qr_code"].(map[string]interface{}); ok {
+			reference, _ = qrCode["external_id"].(string)
+		}
+	}
+	status, _ = payload["status"].(string)
+	created, _ = payload["created"].(string)
+
+	if reference == "" {
+		return nil, ErrMissingReference
+	}
+	amount, err := parseAmount("amount", payload["amount"])
+	if err != nil {
+		return nil, err
+	}
+
+	return &Event{Payment: &PaymentEvent{
+		PartnerReference: reference,
+		Status:           status,
+		Amount:           amount,
+		EventTime:        created,
+		Method:           p.channel.Method,
+	}}, nil
+}
+
+// parseDisbursement reads a disbursement callback (status COMPLETED or FAILED)
+func (p *Xendit) parseDisbursement(data map[string]interface{}) (*Event, error) {
+	externalID, _ := data["external_id"].(string)
+	status, _ := data["status"].(string)
+	updated, _ := data["updated"].(string)
+	if externalID == "" {
+		return nil, ErrMissingReference
+	}
+	amount, err := parseAmount("amount", data["amount"])
+	if err != nil {
+		return nil, err
+	}
+
+	return &Event{Payout: &PayoutEvent{
+		PartnerReference: externalID,
+		Status:           status,
+		Amount:           amount,
+		EventTime:        updated,
+		Method:           p.channel.Method,
+	}}, nil
+}
+
+// Ack answers 200 for stored callbacks; anything else makes Xendit retry
+func (p *Xendit) Ack(outcome, message string) (int, interface{}) {
+	switch outcome {
+	case AckUnauthorized:
+		return http.StatusUnauthorized, map[string]string{"error_code": "INVALID_CALLBACK_TOKEN", "message": message}
+	case AckForbidden:
+		return http.StatusForbidden, map[string]string{"error_code": "REQUEST_FORBIDDEN_ERROR", "message": "Source IP not allowed"}
+	case AckRetryLater:
+		return http.StatusInternalServerError, map[string]string{"error_code": "SERVER_ERROR", "message": "Callback could not be stored, please retry"}
+	default:
+		return http.StatusOK, map[string]string{"status": "ok"}
+	}
+}
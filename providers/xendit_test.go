@@ -0,0 +1,157 @@
+package providers
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kytapay/webhook-v2/models"
+)
+
+// xenditRequest builds a request from a fixture in testdata/xendit
+func xenditRequest(t *testing.T, fixture string) *Request {
+	t.Helper()
+
+	body, err := os.ReadFile(filepath.Join("testdata", "xendit", fixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Request{Method: "POST", Body: body}
+}
+
+func TestXenditParsePayments(t *testing.T) {
+	tests := []struct {
+		name    string
+		channel Channel
+		fixture string
+		want    PaymentEvent
+	}{
+		{
+			name:    "invoice paid",
+			channel: ChannelInvoice,
+			fixture: "invoice_paid.json",
+			want: PaymentEvent{
+				PartnerReference: "KP-INV-20261018-0001",
+				Status:           "PAID",
+				Amount:           models.Rupiah(50000),
+				EventTime:        "2026-10-18T08:15:03.709Z",
+				Method:           "VA",
+			},
+		},
+		{
+			name:    "invoice settled",
+			channel: ChannelInvoice,
+			fixture: "invoice_settled.json",
+			want: PaymentEvent{
+				PartnerReference: "KP-INV-20261018-0002",
+				Status:           "PAID",
+				Amount:           models.Rupiah(125000),
+				EventTime:        "2026-10-18T09:01:12.000Z",
+				Method:           "QRIS",
+				Settlement:       true,
+			},
+		},
+		{
+			name:    "fixed VA payment",
+			channel: ChannelVA,
+			fixture: "va_payment.json",
+			want: PaymentEvent{
+				PartnerReference: "KP-VA-20261018-0003",
+				Status:           "PAID",
+				Amount:           models.Rupiah(75000),
+				EventTime:        "2026-10-18T10:26:13.000Z",
+				Method:           "VA",
+			},
+		},
+		{
+			name:    "qr payment",
+			channel: ChannelQRIS,
+			fixture: "qr_payment.json",
+			want: PaymentEvent{
+				PartnerReference: "KP-QR-20261018-0004",
+				Status:           "SUCCEEDED",
+				Amount:           models.Rupiah(10000),
+				EventTime:        "2026-10-18T11:00:05.000Z",
+				Method:           "QRIS",
+			},
+		},
+		{
+			name:    "qr payment legacy shape",
+			channel: ChannelQRIS,
+			fixture: "qr_payment_legacy.json",
+			want: PaymentEvent{
+				PartnerReference: "KP-QR-20261018-0005",
+				Status:           "COMPLETED",
+				Amount:           models.Rupiah(20000),
+				EventTime:        "2026-10-18T12:30:45.000Z",
+				Method:           "QRIS",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event, err := NewXendit(tt.channel).Parse(xenditRequest(t, tt.fixture))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if event.Payment == nil || event.Payout != nil {
+				t.Fatalf("expected a payment event, got %+v", event)
+			}
+			if *event.Payment != tt.want {
+				t.Errorf("got %+v, want %+v", *event.Payment, tt.want)
+			}
+		})
+	}
+}
+
+func TestXenditParseDisbursement(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    PayoutEvent
+	}{
+		{
+			fixture: "disbursement_completed.json",
+			want: PayoutEvent{
+				PartnerReference: "KP-PO-20261018-0006",
+				Status:           "COMPLETED",
+				Amount:           models.Rupiah(150000),
+				EventTime:        "2026-10-18T13:02:41.000Z",
+				Method:           "Bank",
+			},
+		},
+		{
+			fixture: "disbursement_failed.json",
+			want: PayoutEvent{
+				PartnerReference: "KP-PO-20261018-0007",
+				Status:           "FAILED",
+				Amount:           models.Rupiah(80000) + 50,
+				EventTime:        "2026-10-18T13:06:10.000Z",
+				Method:           "Bank",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			event, err := NewXendit(ChannelPayoutBank).Parse(xenditRequest(t, tt.fixture))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if event.Payout == nil || event.Payment != nil {
+				t.Fatalf("expected a payout event, got %+v", event)
+			}
+			if *event.Payout != tt.want {
+				t.Errorf("got %+v, want %+v", *event.Payout, tt.want)
+			}
+		})
+	}
+}
+
+func TestXenditParseVirtualAccountUpdateIsDropped(t *testing.T) {
+	_, err := NewXendit(ChannelVA).Parse(xenditRequest(t, "va_created.json"))
+	if !errors.Is(err, ErrInvalidPayload) {
+		t.Fatalf("expected ErrInvalidPayload, got %v", err)
+	}
+}
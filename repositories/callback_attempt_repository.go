@@ -0,0 +1,115 @@
+package repositories
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/kytapay/webhook-v2/models"
+)
+
+type CallbackAttemptRepository struct {
+	db DBTX
+}
+
+func NewCallbackAttemptRepository(db *sql.DB) *CallbackAttemptRepository {
+	return &CallbackAttemptRepository{db: db}
+}
+
+// WithTx returns a copy of the repository bound to the given transaction
+func (r *CallbackAttemptRepository) WithTx(tx *sql.Tx) *CallbackAttemptRepository {
+	return &CallbackAttemptRepository{db: tx}
+}
+
+// CreateAttempt stores one merchant callback delivery try
+func (r *CallbackAttemptRepository) CreateAttempt(attempt *models.CallbackAttempt) error {
+	query := `INSERT INTO callback_attempts 
+		(callback_status_id, transaction_info_id, attempt_number, url, request_headers, request_payload, http_status, response_snippet, duration_ms, error_class, error_message, created_at) 
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	now := time.Now()
+	result, err := r.db.Exec(query,
+		attempt.CallbackStatusID,
+		attempt.TransactionInfoID,
+		attempt.AttemptNumber,
+		attempt.URL,
+		attempt.RequestHeaders,
+		attempt.RequestPayload,
+		attempt.HTTPStatus,
+		attempt.ResponseSnippet,
+		attempt.DurationMs,
+		attempt.ErrorClass,
+		attempt.ErrorMessage,
+		now,
+	)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	attempt.ID = id
+	attempt.CreatedAt = &now
+	return nil
+}
+
+// GetAttemptsByTransactionInfoID gets every delivery try for a transaction, oldest first
+func (r *CallbackAttemptRepository) GetAttemptsByTransactionInfoID(transactionInfoID int) ([]models.CallbackAttempt, error) {
+	query := `SELECT id, callback_status_id, transaction_info_id, attempt_number, url, request_headers, request_payload, http_status, response_snippet, duration_ms, error_class, error_message, created_at 
+		FROM callback_attempts WHERE transaction_info_id = ? ORDER BY id ASC`
+
+	rows, err := r.db.Query(query, transactionInfoID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var attempts []models.CallbackAttempt
+	for rows.Next() {
+		var attempt models.CallbackAttempt
+		var httpStatus sql.NullInt64
+		var responseSnippet, errorMessage sql.NullString
+		if err := rows.Scan(
+			&attempt.ID,
+			&attempt.CallbackStatusID,
+			&attempt.TransactionInfoID,
+			&attempt.AttemptNumber,
+			&attempt.URL,
+			&attempt.RequestHeaders,
+			&attempt.RequestPayload,
+			&httpStatus,
+			&responseSnippet,
+			&attempt.DurationMs,
+			&attempt.ErrorClass,
+			&errorMessage,
+			&attempt.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+
+		if httpStatus.Valid {
+			val := int(httpStatus.Int64)
+			attempt.HTTPStatus = &val
+		}
+		if responseSnippet.Valid {
+			attempt.ResponseSnippet = &responseSnippet.String
+		}
+		if errorMessage.Valid {
+			attempt.ErrorMessage = &errorMessage.String
+		}
+		attempts = append(attempts, attempt)
+	}
+
+	return attempts, rows.Err()
+}
+
+// CountAttemptsByTransactionInfoID counts delivery tries made for a transaction
+func (r *CallbackAttemptRepository) CountAttemptsByTransactionInfoID(transactionInfoID int) (int, error) {
+	query := `SELECT COUNT(*) FROM callback_attempts WHERE transaction_info_id = ?`
+
+	var count int
+	err := r.db.QueryRow(query, transactionInfoID).Scan(&count)
+	return count, err
+}
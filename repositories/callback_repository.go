@@ -9,22 +9,107 @@ import (
 )
 
 type CallbackRepository struct {
-	db *sql.DB
+	db DBTX
 }
 
 func NewCallbackRepository(db *sql.DB) *CallbackRepository {
 	return &CallbackRepository{db: db}
 }
 
+// WithTx returns a copy of the repository bound to the given transaction
+func (r *CallbackRepository) WithTx(tx *sql.Tx) *CallbackRepository {
+	return &CallbackRepository{db: tx}
+}
+
+// callbackColumns is the column list shared by every callback_status SELECT
+const callbackColumns = `id, transaction_info_id, merchant_id, notify_url, status, error_message, response_body, payload, retry_count, next_retry_at, created_at, updated_at`
+
 // GetCallbackByTransactionInfoID gets callback by transaction_info_id
 func (r *CallbackRepository) GetCallbackByTransactionInfoID(transactionInfoID int) (*models.CallbackStatus, error) {
-	query := `SELECT id, transaction_info_id, merchant_id, notify_url, status, error_message, response_body, payload, retry_count, created_at, updated_at 
+	query := `SELECT ` + callbackColumns + ` 
 		FROM callback_status WHERE transaction_info_id = ? LIMIT 1`
 
+	rows, err := r.db.Query(query, transactionInfoID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		return nil, sql.ErrNoRows
+	}
+
+	return scanCallback(rows)
+}
+
+// GetDueRetries gets failed callbacks whose next retry time has passed, and claimed retries whose lease
+// expired (the instance delivering them died before recording the result)
+func (r *CallbackRepository) GetDueRetries(now time.Time, limit int) ([]models.CallbackStatus, error) {
+	query := `SELECT ` + callbackColumns + ` 
+		FROM callback_status WHERE status IN (?, ?) AND next_retry_at IS NOT NULL AND next_retry_at <= ? ORDER BY next_retry_at ASC LIMIT ?`
+
+	rows, err := r.db.Query(query, models.CallbackStatusFailed, models.CallbackStatusRetrying, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var callbacks []models.CallbackStatus
+	for rows.Next() {
+		callback, err := scanCallback(rows)
+		if err != nil {
+			return nil, err
+		}
+		callbacks = append(callbacks, *callback)
+	}
+
+	return callbacks, rows.Err()
+}
+
+// ClaimRetry marks a due callback as Retrying until leaseUntil so only one instance redelivers it;
+// next_retry_at holds the lease, so a claim that is never completed becomes due again
+// Returns the claimed row, or nil when another instance already claimed it
+func (r *CallbackRepository) ClaimRetry(id int, leaseUntil time.Time) (*models.CallbackStatus, error) {
+	// next_retry_at is stored with second precision, the lease must compare equal when it is read back
+	leaseUntil = leaseUntil.Truncate(time.Second)
+
+	query := `UPDATE callback_status SET status = ?, next_retry_at = ?, updated_at = ? 
+		WHERE id = ? AND status IN (?, ?) AND next_retry_at IS NOT NULL AND next_retry_at <= ?`
+	now := time.Now()
+	result, err := r.db.Exec(query, models.CallbackStatusRetrying, leaseUntil, now, id, models.CallbackStatusFailed, models.CallbackStatusRetrying, now)
+	if err != nil {
+		return nil, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil || affected != 1 {
+		return nil, err
+	}
+
+	// Read the row as claimed; the caller's copy may predate a newer notification
+	query = `SELECT ` + callbackColumns + ` 
+		FROM callback_status WHERE id = ? AND status = ? AND next_retry_at = ?`
+	rows, err := r.db.Query(query, id, models.CallbackStatusRetrying, leaseUntil)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+	return scanCallback(rows)
+}
+
+// scanCallback scans one row selected with callbackColumns
+func scanCallback(rows *sql.Rows) (*models.CallbackStatus, error) {
 	var callback models.CallbackStatus
 	var errorMsg, responseBody, payload sql.NullString
 
-	err := r.db.QueryRow(query, transactionInfoID).Scan(
+	err := rows.Scan(
 		&callback.ID,
 		&callback.TransactionInfoID,
 		&callback.MerchantID,
@@ -34,6 +119,7 @@ func (r *CallbackRepository) GetCallbackByTransactionInfoID(transactionInfoID in
 		&responseBody,
 		&payload,
 		&callback.RetryCount,
+		&callback.NextRetryAt,
 		&callback.CreatedAt,
 		&callback.UpdatedAt,
 	)
@@ -55,14 +141,29 @@ func (r *CallbackRepository) GetCallbackByTransactionInfoID(transactionInfoID in
 	return &callback, nil
 }
 
-// UpdateCallback updates callback status
-func (r *CallbackRepository) UpdateCallback(transactionInfoID int, status, errorMessage, responseBody string, payloadData interface{}) error {
+// UpdateCallback updates callback status after the first delivery attempt of a new notification
+// nextRetryAt schedules an automatic retry (nil means no further retry); retry_count starts again from zero
+func (r *CallbackRepository) UpdateCallback(transactionInfoID int, status, errorMessage, responseBody string, payloadData interface{}, nextRetryAt *time.Time) error {
 	payloadJSON, _ := json.Marshal(payloadData)
 	payloadStr := string(payloadJSON)
 
-	query := `UPDATE callback_status SET status = ?, error_message = ?, response_body = ?, payload = ?, updated_at = ? WHERE transaction_info_id = ?`
+	query := `UPDATE callback_status SET status = ?, error_message = ?, response_body = ?, payload = ?, retry_count = 0, next_retry_at = ?, updated_at = ? WHERE transaction_info_id = ?`
 	now := time.Now()
-	_, err := r.db.Exec(query, status, errorMessage, responseBody, payloadStr, now, transactionInfoID)
+	_, err := r.db.Exec(query, status, errorMessage, responseBody, payloadStr, nextRetryAt, now, transactionInfoID)
 	return err
 }
 
+// UpdateRetry records the result of an automatic retry and increments retry_count
+// Only the claim holding the lease (status Retrying, next_retry_at = lease) may write; returns false when
+// the claim was lost, e.g. a newer notification was scheduled by UpdateCallback while the retry was in flight
+func (r *CallbackRepository) UpdateRetry(id int, lease time.Time, status, errorMessage, responseBody string, nextRetryAt *time.Time) (bool, error) {
+	query := `UPDATE callback_status SET status = ?, error_message = ?, response_body = ?, retry_count = retry_count + 1, next_retry_at = ?, updated_at = ? 
+		WHERE id = ? AND status = ? AND next_retry_at = ?`
+	result, err := r.db.Exec(query, status, errorMessage, responseBody, nextRetryAt, time.Now(), id, models.CallbackStatusRetrying, lease)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected == 1, err
+}
@@ -0,0 +1,11 @@
+package repositories
+
+import "database/sql"
+
+// DBTX is implemented by both *sql.DB and *sql.Tx, so every repository can
+// run its queries either directly or inside a database transaction
+type DBTX interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
@@ -7,13 +7,18 @@ import (
 )
 
 type FeesRepository struct {
-	db *sql.DB
+	db DBTX
 }
 
 func NewFeesRepository(db *sql.DB) *FeesRepository {
 	return &FeesRepository{db: db}
 }
 
+// WithTx returns a copy of the repository bound to the given transaction
+func (r *FeesRepository) WithTx(tx *sql.Tx) *FeesRepository {
+	return &FeesRepository{db: tx}
+}
+
 // GetFeesLimit gets fees limit by transaction_type_id and payment_method_id
 func (r *FeesRepository) GetFeesLimit(transactionTypeID, paymentMethodID int) (*models.FeesLimit, error) {
 	query := `SELECT id, currency_id, transaction_type_id, payment_method_id, charge_percentage, charge_fixed, min_limit, max_limit, processing_time, has_transaction 
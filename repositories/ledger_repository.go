@@ -0,0 +1,178 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/kytapay/webhook-v2/models"
+)
+
+type LedgerRepository struct {
+	db DBTX
+}
+
+func NewLedgerRepository(db *sql.DB) *LedgerRepository {
+	return &LedgerRepository{db: db}
+}
+
+// WithTx returns a copy of the repository bound to the given transaction
+func (r *LedgerRepository) WithTx(tx *sql.Tx) *LedgerRepository {
+	return &LedgerRepository{db: tx}
+}
+
+// CreateEntries inserts a balanced set of ledger legs (total debit must equal total credit)
+func (r *LedgerRepository) CreateEntries(entries []models.LedgerEntry) error {
+	var debit, credit models.Money
+	for _, entry := range entries {
+		switch entry.Direction {
+		case models.LedgerDebit:
+			debit += entry.Amount
+		case models.LedgerCredit:
+			credit += entry.Amount
+		default:
+			return fmt.Errorf("invalid ledger direction %q", entry.Direction)
+		}
+	}
+	if debit != credit {
+		return fmt.Errorf("unbalanced ledger entries: debit %s, credit %s", debit, credit)
+	}
+
+	query := `INSERT INTO ledger_entries (grant_id, user_id, account, direction, amount, description, created_at) 
+		VALUES (?, ?, ?, ?, ?, ?, ?)`
+
+	now := time.Now()
+	for _, entry := range entries {
+		_, err := r.db.Exec(query, entry.GrantID, entry.UserID, entry.Account, entry.Direction, entry.Amount, entry.Description, now)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// EnsureOpeningBalance records the wallet balance that existed before the ledger was introduced,
+// so the merchant_wallet account can always be derived from ledger entries alone
+func (r *LedgerRepository) EnsureOpeningBalance(userID int, balance models.Money) error {
+	var count int
+	query := `SELECT COUNT(*) FROM ledger_entries WHERE user_id = ? AND account = ?`
+	if err := r.db.QueryRow(query, userID, models.LedgerAccountMerchantWallet).Scan(&count); err != nil {
+		return err
+	}
+	if count > 0 || balance == 0 {
+		return nil
+	}
+
+	// A negative opening balance is recorded with reversed legs
+	walletDirection, openingDirection := models.LedgerCredit, models.LedgerDebit
+	if balance < 0 {
+		walletDirection, openingDirection = models.LedgerDebit, models.LedgerCredit
+		balance = -balance
+	}
+
+	grantID := fmt.Sprintf("OPENING-%d", userID)
+	return r.CreateEntries([]models.LedgerEntry{
+		{GrantID: grantID, UserID: userID, Account: models.LedgerAccountOpeningBalance, Direction: openingDirection, Amount: balance, Description: "Opening wallet balance"},
+		{GrantID: grantID, UserID: userID, Account: models.LedgerAccountMerchantWallet, Direction: walletDirection, Amount: balance, Description: "Opening wallet balance"},
+	})
+}
+
+// ReverseGrant writes the opposite legs of the net ledger position of a grant for a user,
+// e.g. when a payment is refunded or a payout is returned; already reversed legs net to zero and are skipped
+// Returns the change of the merchant_wallet account, to be applied to the wallet balance
+func (r *LedgerRepository) ReverseGrant(grantID string, userID int, description string) (models.Money, error) {
+	query := `SELECT account, COALESCE(SUM(CASE WHEN direction = 'credit' THEN amount ELSE -amount END), 0) 
+		FROM ledger_entries WHERE grant_id = ? AND user_id = ? GROUP BY account ORDER BY account`
+	rows, err := r.db.Query(query, grantID, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	var entries []models.LedgerEntry
+	var walletChange models.Money
+	for rows.Next() {
+		var account string
+		var net models.Money
+		if err := rows.Scan(&account, &net); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if net == 0 {
+			continue
+		}
+
+		// A net credit is reversed with a debit and vice versa
+		entry := models.LedgerEntry{GrantID: grantID, UserID: userID, Account: account, Direction: models.LedgerDebit, Amount: net, Description: description}
+		if net < 0 {
+			entry.Direction, entry.Amount = models.LedgerCredit, -net
+		}
+		entries = append(entries, entry)
+
+		if account == models.LedgerAccountMerchantWallet {
+			walletChange = -net
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	if len(entries) == 0 {
+		return 0, nil
+	}
+	return walletChange, r.CreateEntries(entries)
+}
+
+// GetDerivedWalletBalance computes the wallet balance from merchant_wallet ledger entries
+func (r *LedgerRepository) GetDerivedWalletBalance(userID int) (models.Money, error) {
+	query := `SELECT COALESCE(SUM(CASE WHEN direction = 'credit' THEN amount ELSE -amount END), 0) 
+		FROM ledger_entries WHERE user_id = ? AND account = ?`
+
+	var balance models.Money
+	err := r.db.QueryRow(query, userID, models.LedgerAccountMerchantWallet).Scan(&balance)
+	return balance, err
+}
+
+// ListByUserID lists ledger entries for a user, newest first
+func (r *LedgerRepository) ListByUserID(userID int, limit int) ([]models.LedgerEntry, error) {
+	query := `SELECT id, grant_id, user_id, account, direction, amount, description, created_at 
+		FROM ledger_entries WHERE user_id = ? ORDER BY id DESC LIMIT ?`
+	return r.list(query, userID, limit)
+}
+
+// ListByGrantID lists all ledger legs written for a grant_id
+func (r *LedgerRepository) ListByGrantID(grantID string) ([]models.LedgerEntry, error) {
+	query := `SELECT id, grant_id, user_id, account, direction, amount, description, created_at 
+		FROM ledger_entries WHERE grant_id = ? ORDER BY id ASC`
+	return r.list(query, grantID)
+}
+
+// list runs a ledger entry query and scans every row
+func (r *LedgerRepository) list(query string, args ...interface{}) ([]models.LedgerEntry, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []models.LedgerEntry
+	for rows.Next() {
+		var entry models.LedgerEntry
+		if err := rows.Scan(
+			&entry.ID,
+			&entry.GrantID,
+			&entry.UserID,
+			&entry.Account,
+			&entry.Direction,
+			&entry.Amount,
+			&entry.Description,
+			&entry.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
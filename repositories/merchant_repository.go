@@ -7,20 +7,37 @@ import (
 )
 
 type MerchantRepository struct {
-	db *sql.DB
+	db DBTX
 }
 
 func NewMerchantRepository(db *sql.DB) *MerchantRepository {
 	return &MerchantRepository{db: db}
 }
 
+// WithTx returns a copy of the repository bound to the given transaction
+func (r *MerchantRepository) WithTx(tx *sql.Tx) *MerchantRepository {
+	return &MerchantRepository{db: tx}
+}
+
 // GetMerchantPaymentByGatewayRef gets merchant payment by gateway_reference
 func (r *MerchantRepository) GetMerchantPaymentByGatewayRef(gatewayRef string) (*models.MerchantPayment, error) {
 	query := `SELECT id, merchant_id, payment_method_id, gateway_reference, order_no, uuid, fee_bearer, percentage, charge_percentage, charge_fixed, amount, total, status, created_at, updated_at 
 		FROM merchant_payments WHERE gateway_reference = ? LIMIT 1`
+	return r.scanMerchantPayment(query, gatewayRef)
+}
+
+// GetMerchantPaymentByGatewayRefForUpdate gets merchant payment by gateway_reference and locks the row
+// so concurrent callbacks for the same payment are serialized (must be called with WithTx)
+func (r *MerchantRepository) GetMerchantPaymentByGatewayRefForUpdate(gatewayRef string) (*models.MerchantPayment, error) {
+	query := `SELECT id, merchant_id, payment_method_id, gateway_reference, order_no, uuid, fee_bearer, percentage, charge_percentage, charge_fixed, amount, total, status, created_at, updated_at 
+		FROM merchant_payments WHERE gateway_reference = ? LIMIT 1 FOR UPDATE`
+	return r.scanMerchantPayment(query, gatewayRef)
+}
 
+// scanMerchantPayment runs a single-row merchant payment query
+func (r *MerchantRepository) scanMerchantPayment(query string, args ...interface{}) (*models.MerchantPayment, error) {
 	var payment models.MerchantPayment
-	err := r.db.QueryRow(query, gatewayRef).Scan(
+	err := r.db.QueryRow(query, args...).Scan(
 		&payment.ID,
 		&payment.MerchantID,
 		&payment.PaymentMethodID,
@@ -46,7 +63,7 @@ func (r *MerchantRepository) GetMerchantPaymentByGatewayRef(gatewayRef string) (
 }
 
 // UpdateMerchantPayment updates merchant payment status and amount
-func (r *MerchantRepository) UpdateMerchantPayment(gatewayRef string, status string, amount float64) error {
+func (r *MerchantRepository) UpdateMerchantPayment(gatewayRef string, status string, amount models.Money) error {
 	query := `UPDATE merchant_payments SET status = ?, amount = ?, updated_at = NOW() WHERE gateway_reference = ?`
 	_, err := r.db.Exec(query, status, amount, gatewayRef)
 	return err
@@ -54,7 +71,7 @@ func (r *MerchantRepository) UpdateMerchantPayment(gatewayRef string, status str
 
 // GetMerchantByID gets merchant by ID
 func (r *MerchantRepository) GetMerchantByID(merchantID int) (*models.Merchant, error) {
-	query := `SELECT id, user_id, business_name, merchant_uuid, site_url, status FROM merchants WHERE id = ? LIMIT 1`
+	query := `SELECT id, user_id, business_name, merchant_uuid, site_url, status, callback_secret FROM merchants WHERE id = ? LIMIT 1`
 
 	var merchant models.Merchant
 	err := r.db.QueryRow(query, merchantID).Scan(
@@ -64,6 +81,7 @@ func (r *MerchantRepository) GetMerchantByID(merchantID int) (*models.Merchant,
 		&merchant.MerchantUUID,
 		&merchant.SiteURL,
 		&merchant.Status,
+		&merchant.CallbackSecret,
 	)
 
 	if err != nil {
@@ -77,12 +95,24 @@ func (r *MerchantRepository) GetMerchantByID(merchantID int) (*models.Merchant,
 func (r *MerchantRepository) GetMerchantPayoutByGatewayRef(gatewayRef string) (*models.MerchantPayout, error) {
 	query := `SELECT id, merchant_id, currency_id, payment_method_id, user_id, gateway_reference, order_no, item_name, uuid, fee_bearer, percentage, charge_percentage, charge_fixed, amount, total, status, bank_name, account_name, account_number, created_at, updated_at 
 		FROM merchant_payouts WHERE gateway_reference = ? LIMIT 1`
+	return r.scanMerchantPayout(query, gatewayRef)
+}
+
+// GetMerchantPayoutByGatewayRefForUpdate gets merchant payout by gateway_reference and locks the row
+// so concurrent callbacks for the same payout are serialized (must be called with WithTx)
+func (r *MerchantRepository) GetMerchantPayoutByGatewayRefForUpdate(gatewayRef string) (*models.MerchantPayout, error) {
+	query := `SELECT id, merchant_id, currency_id, payment_method_id, user_id, gateway_reference, order_no, item_name, uuid, fee_bearer, percentage, charge_percentage, charge_fixed, amount, total, status, bank_name, account_name, account_number, created_at, updated_at 
+		FROM merchant_payouts WHERE gateway_reference = ? LIMIT 1 FOR UPDATE`
+	return r.scanMerchantPayout(query, gatewayRef)
+}
 
+// scanMerchantPayout runs a single-row merchant payout query
+func (r *MerchantRepository) scanMerchantPayout(query string, args ...interface{}) (*models.MerchantPayout, error) {
 	var payout models.MerchantPayout
 	var currencyID, userID sql.NullInt64
 	var itemName, bankName, accountName, accountNumber sql.NullString
 
-	err := r.db.QueryRow(query, gatewayRef).Scan(
+	err := r.db.QueryRow(query, args...).Scan(
 		&payout.ID,
 		&payout.MerchantID,
 		&currencyID,
@@ -135,7 +165,7 @@ func (r *MerchantRepository) GetMerchantPayoutByGatewayRef(gatewayRef string) (*
 }
 
 // UpdateMerchantPayout updates merchant payout status and amount
-func (r *MerchantRepository) UpdateMerchantPayout(gatewayRef string, status string, amount float64) error {
+func (r *MerchantRepository) UpdateMerchantPayout(gatewayRef string, status string, amount models.Money) error {
 	query := `UPDATE merchant_payouts SET status = ?, amount = ?, updated_at = NOW() WHERE gateway_reference = ?`
 	_, err := r.db.Exec(query, status, amount, gatewayRef)
 	return err
@@ -0,0 +1,51 @@
+package repositories
+
+import (
+	"database/sql"
+	"time"
+)
+
+type SnapExternalIDRepository struct {
+	db DBTX
+}
+
+func NewSnapExternalIDRepository(db *sql.DB) *SnapExternalIDRepository {
+	return &SnapExternalIDRepository{db: db}
+}
+
+// WithTx returns a copy of the repository bound to the given transaction
+func (r *SnapExternalIDRepository) WithTx(tx *sql.Tx) *SnapExternalIDRepository {
+	return &SnapExternalIDRepository{db: tx}
+}
+
+// Claim records an X-EXTERNAL-ID for a partner and day (YYYY-MM-DD)
+// Returns false when the partner already used the external id on that day
+func (r *SnapExternalIDRepository) Claim(provider, day, externalID string) (bool, error) {
+	result, err := r.db.Exec(`INSERT IGNORE INTO snap_external_ids (provider, external_id, request_date, created_at) VALUES (?, ?, ?, ?)`,
+		provider, externalID, day, time.Now())
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows == 1, nil
+}
+
+// Release removes a claimed external id, e.g. when the request could not be stored and the partner will retry
+func (r *SnapExternalIDRepository) Release(provider, day, externalID string) error {
+	_, err := r.db.Exec(`DELETE FROM snap_external_ids WHERE provider = ? AND request_date = ? AND external_id = ?`,
+		provider, day, externalID)
+	return err
+}
+
+// DeleteBefore removes external ids of days before day; they can no longer collide
+func (r *SnapExternalIDRepository) DeleteBefore(day string) (int64, error) {
+	result, err := r.db.Exec(`DELETE FROM snap_external_ids WHERE request_date < ? LIMIT 1000`, day)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
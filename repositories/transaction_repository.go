@@ -8,20 +8,64 @@ import (
 )
 
 type TransactionRepository struct {
-	db *sql.DB
+	db DBTX
 }
 
 func NewTransactionRepository(db *sql.DB) *TransactionRepository {
 	return &TransactionRepository{db: db}
 }
 
+// WithTx returns a copy of the repository bound to the given transaction
+func (r *TransactionRepository) WithTx(tx *sql.Tx) *TransactionRepository {
+	return &TransactionRepository{db: tx}
+}
+
+// transactionInfoColumns is the column list shared by every app_transactions_infos SELECT
+const transactionInfoColumns = `id, app_id, order_id, payment_method, amount, currency, notify_url, success_url, cancel_url, grant_id, token, bank_number, bank_ewallet_name, qris_string, ewallet_link, status, version, created_at, updated_at`
+
 // GetTransactionByGrantID gets transaction info by grant_id
 func (r *TransactionRepository) GetTransactionByGrantID(grantID string) (*models.TransactionInfo, error) {
-	query := `SELECT id, app_id, order_id, payment_method, amount, currency, notify_url, success_url, cancel_url, grant_id, token, bank_number, bank_ewallet_name, qris_string, ewallet_link, status, version, created_at, updated_at 
+	query := `SELECT ` + transactionInfoColumns + ` 
 		FROM app_transactions_infos WHERE grant_id = ? LIMIT 1`
+	return r.scanTransactionInfo(query, grantID)
+}
+
+// GetTransactionByID gets transaction info by id
+func (r *TransactionRepository) GetTransactionByID(id int) (*models.TransactionInfo, error) {
+	query := `SELECT ` + transactionInfoColumns + ` 
+		FROM app_transactions_infos WHERE id = ? LIMIT 1`
+	return r.scanTransactionInfo(query, id)
+}
+
+// GetTransactionByBankNumber gets the latest transaction info for a virtual account number, used by the SNAP VA inquiry
+// It is the only query reading customer_name (migration 010), so the payment pipeline does not depend on that column
+func (r *TransactionRepository) GetTransactionByBankNumber(bankNumber string) (*models.TransactionInfo, error) {
+	query := `SELECT ` + transactionInfoColumns + `, customer_name 
+		FROM app_transactions_infos WHERE bank_number = ? ORDER BY id DESC LIMIT 1`
 
 	var transaction models.TransactionInfo
-	err := r.db.QueryRow(query, grantID).Scan(
+	err := r.db.QueryRow(query, bankNumber).Scan(append(transactionInfoFields(&transaction), &transaction.CustomerName)...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &transaction, nil
+}
+
+// scanTransactionInfo runs a single-row transaction info query
+func (r *TransactionRepository) scanTransactionInfo(query string, args ...interface{}) (*models.TransactionInfo, error) {
+	var transaction models.TransactionInfo
+	err := r.db.QueryRow(query, args...).Scan(transactionInfoFields(&transaction)...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &transaction, nil
+}
+
+// transactionInfoFields returns the scan destinations matching transactionInfoColumns
+func transactionInfoFields(transaction *models.TransactionInfo) []interface{} {
+	return []interface{}{
 		&transaction.ID,
 		&transaction.AppID,
 		&transaction.OrderID,
@@ -41,20 +85,23 @@ func (r *TransactionRepository) GetTransactionByGrantID(grantID string) (*models
 		&transaction.Version,
 		&transaction.CreatedAt,
 		&transaction.UpdatedAt,
-	)
-
-	if err != nil {
-		return nil, err
 	}
-
-	return &transaction, nil
 }
 
 // UpdateTransaction updates transaction status and amount
-func (r *TransactionRepository) UpdateTransaction(grantID string, status string, amount int64) error {
+// app_transactions_infos.amount holds whole rupiah, so the amount is rounded half away from zero
+func (r *TransactionRepository) UpdateTransaction(grantID string, status string, amount models.Money) error {
 	query := `UPDATE app_transactions_infos SET status = ?, amount = ?, updated_at = ? WHERE grant_id = ?`
 	now := time.Now()
-	_, err := r.db.Exec(query, status, amount, now, grantID)
+	_, err := r.db.Exec(query, status, amount.Rupiah(), now, grantID)
+	return err
+}
+
+// RecordAmountCheck stores the amount reported by the payment callback and the result of comparing it with the order amount
+func (r *TransactionRepository) RecordAmountCheck(grantID string, paidAmount models.Money, amountStatus string) error {
+	query := `UPDATE app_transactions_infos SET paid_amount = ?, amount_status = ?, updated_at = ? WHERE grant_id = ?`
+	now := time.Now()
+	_, err := r.db.Exec(query, paidAmount, amountStatus, now, grantID)
 	return err
 }
 
@@ -135,3 +182,11 @@ func (r *TransactionRepository) UpdateTransactions(grantID string, paymentStatus
 	return err
 }
 
+// UpdateTransactionsAmounts updates the subtotal, percentage charge and total of a transactions record,
+// e.g. when a partial payment is settled with the amount actually paid
+func (r *TransactionRepository) UpdateTransactionsAmounts(grantID string, subtotal, chargePercentage, total models.Money) error {
+	query := `UPDATE transactions SET subtotal = ?, charge_percentage = ?, total = ?, updated_at = ? WHERE grant_id = ?`
+	now := time.Now()
+	_, err := r.db.Exec(query, subtotal, chargePercentage, total, now, grantID)
+	return err
+}
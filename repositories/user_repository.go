@@ -7,13 +7,18 @@ import (
 )
 
 type UserRepository struct {
-	db *sql.DB
+	db DBTX
 }
 
 func NewUserRepository(db *sql.DB) *UserRepository {
 	return &UserRepository{db: db}
 }
 
+// WithTx returns a copy of the repository bound to the given transaction
+func (r *UserRepository) WithTx(tx *sql.Tx) *UserRepository {
+	return &UserRepository{db: tx}
+}
+
 // GetUserByID gets user by ID
 func (r *UserRepository) GetUserByID(userID int) (*models.User, error) {
 	query := `SELECT id, email, role_id FROM users WHERE id = ? LIMIT 1`
@@ -6,19 +6,35 @@ import (
 )
 
 type WalletRepository struct {
-	db *sql.DB
+	db DBTX
 }
 
 func NewWalletRepository(db *sql.DB) *WalletRepository {
 	return &WalletRepository{db: db}
 }
 
+// WithTx returns a copy of the repository bound to the given transaction
+func (r *WalletRepository) WithTx(tx *sql.Tx) *WalletRepository {
+	return &WalletRepository{db: tx}
+}
+
 // GetUserWallet gets user wallet by user_id
 func (r *WalletRepository) GetUserWallet(userID int) (*models.Wallet, error) {
 	query := `SELECT id, user_id, balance, created_at, updated_at FROM wallets WHERE user_id = ? LIMIT 1`
+	return r.scanWallet(query, userID)
+}
 
+// GetUserWalletForUpdate gets user wallet by user_id and locks the row until the
+// surrounding transaction ends (must be called on a repository bound with WithTx)
+func (r *WalletRepository) GetUserWalletForUpdate(userID int) (*models.Wallet, error) {
+	query := `SELECT id, user_id, balance, created_at, updated_at FROM wallets WHERE user_id = ? LIMIT 1 FOR UPDATE`
+	return r.scanWallet(query, userID)
+}
+
+// scanWallet runs a single-row wallet query
+func (r *WalletRepository) scanWallet(query string, args ...interface{}) (*models.Wallet, error) {
 	var wallet models.Wallet
-	err := r.db.QueryRow(query, userID).Scan(
+	err := r.db.QueryRow(query, args...).Scan(
 		&wallet.ID,
 		&wallet.UserID,
 		&wallet.Balance,
@@ -33,10 +49,22 @@ func (r *WalletRepository) GetUserWallet(userID int) (*models.Wallet, error) {
 	return &wallet, nil
 }
 
-// UpdateWalletBalance updates wallet balance
-func (r *WalletRepository) UpdateWalletBalance(userID int, balance float64) error {
-	query := `UPDATE wallets SET balance = ?, updated_at = NOW() WHERE user_id = ?`
-	_, err := r.db.Exec(query, balance, userID)
-	return err
-}
+// IncrementWalletBalance atomically adds delta to the wallet balance (negative delta deducts)
+// The balance is never computed in Go, so concurrent callbacks cannot overwrite each other
+func (r *WalletRepository) IncrementWalletBalance(userID int, delta models.Money) error {
+	query := `UPDATE wallets SET balance = balance + CAST(? AS DECIMAL(20, 2)), updated_at = NOW() WHERE user_id = ?`
+	result, err := r.db.Exec(query, delta, userID)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
 
+	return nil
+}
@@ -0,0 +1,276 @@
+package repositories
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/kytapay/webhook-v2/models"
+)
+
+// webhookEventColumns is the column list shared by every webhook_events SELECT
+const webhookEventColumns = `id, provider, channel, route, source_ip, headers, body, partner_reference, verification_status, processing_status, processing_message, event_type, event_status, amount, event_time, method, amount_policy, attempts, available_at, locked_by, locked_at, created_at, updated_at`
+
+type WebhookEventRepository struct {
+	db DBTX
+}
+
+func NewWebhookEventRepository(db *sql.DB) *WebhookEventRepository {
+	return &WebhookEventRepository{db: db}
+}
+
+// WithTx returns a copy of the repository bound to the given transaction
+func (r *WebhookEventRepository) WithTx(tx *sql.Tx) *WebhookEventRepository {
+	return &WebhookEventRepository{db: tx}
+}
+
+// CreateEvent stores a raw inbound callback and sets its ID
+func (r *WebhookEventRepository) CreateEvent(event *models.WebhookEvent) error {
+	query := `INSERT INTO webhook_events 
+		(provider, channel, route, source_ip, headers, body, partner_reference, verification_status, processing_status, processing_message, created_at, updated_at) 
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	now := time.Now()
+	result, err := r.db.Exec(query,
+		event.Provider,
+		event.Channel,
+		event.Route,
+		event.SourceIP,
+		event.Headers,
+		event.Body,
+		event.PartnerReference,
+		event.VerificationStatus,
+		event.ProcessingStatus,
+		event.ProcessingMessage,
+		now,
+		now,
+	)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	event.ID = id
+	event.CreatedAt = &now
+	event.UpdatedAt = &now
+	return nil
+}
+
+// UpdateVerification updates the signature/credential verification result
+func (r *WebhookEventRepository) UpdateVerification(id int64, verificationStatus string) error {
+	query := `UPDATE webhook_events SET verification_status = ?, updated_at = ? WHERE id = ?`
+	_, err := r.db.Exec(query, verificationStatus, time.Now(), id)
+	return err
+}
+
+// UpdateOutcome updates partner reference and processing outcome
+func (r *WebhookEventRepository) UpdateOutcome(id int64, partnerReference, processingStatus, processingMessage string) error {
+	query := `UPDATE webhook_events SET partner_reference = NULLIF(?, ''), processing_status = ?, processing_message = NULLIF(?, ''), updated_at = ? WHERE id = ?`
+	_, err := r.db.Exec(query, partnerReference, processingStatus, processingMessage, time.Now(), id)
+	return err
+}
+
+// GetEventsByPartnerReference gets all callbacks received for a partner reference, oldest first
+func (r *WebhookEventRepository) GetEventsByPartnerReference(partnerReference string) ([]models.WebhookEvent, error) {
+	query := `SELECT ` + webhookEventColumns + ` 
+		FROM webhook_events WHERE partner_reference = ? ORDER BY id ASC`
+	return r.list(query, partnerReference)
+}
+
+// GetEventsByTimeRange gets callbacks received between from and to (inclusive), newest first
+func (r *WebhookEventRepository) GetEventsByTimeRange(from, to time.Time, limit int) ([]models.WebhookEvent, error) {
+	query := `SELECT ` + webhookEventColumns + ` 
+		FROM webhook_events WHERE created_at BETWEEN ? AND ? ORDER BY id DESC LIMIT ?`
+	return r.list(query, from, to, limit)
+}
+
+// list runs a webhook event query and scans every row
+func (r *WebhookEventRepository) list(query string, args ...interface{}) ([]models.WebhookEvent, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []models.WebhookEvent
+	for rows.Next() {
+		event, err := scanWebhookEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, *event)
+	}
+
+	return events, rows.Err()
+}
+
+// QueueEvent stores the parsed callback fields and hands the event over to the webhook worker
+func (r *WebhookEventRepository) QueueEvent(event *models.WebhookEvent) error {
+	query := `UPDATE webhook_events SET partner_reference = ?, event_type = ?, event_status = ?, amount = ?, event_time = ?, method = ?, 
+		processing_status = ?, available_at = ?, updated_at = ? WHERE id = ?`
+
+	now := time.Now()
+	_, err := r.db.Exec(query,
+		event.PartnerReference,
+		event.EventType,
+		event.EventStatus,
+		event.Amount,
+		event.EventTime,
+		event.Method,
+		models.WebhookStatusQueued,
+		now,
+		now,
+		event.ID,
+	)
+	return err
+}
+
+// ClaimNextEvent locks the oldest due queued event for workerID
+// Returns nil, nil when nothing is due
+func (r *WebhookEventRepository) ClaimNextEvent(workerID string) (*models.WebhookEvent, error) {
+	now := time.Now()
+	query := `UPDATE webhook_events SET processing_status = ?, locked_by = ?, locked_at = ?, attempts = attempts + 1, updated_at = ? 
+		WHERE processing_status = ? AND available_at <= ? ORDER BY id ASC LIMIT 1`
+
+	result, err := r.db.Exec(query, models.WebhookStatusProcessing, workerID, now, now, models.WebhookStatusQueued, now)
+	if err != nil {
+		return nil, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil || affected == 0 {
+		return nil, err
+	}
+
+	query = `SELECT ` + webhookEventColumns + ` 
+		FROM webhook_events WHERE processing_status = ? AND locked_by = ? ORDER BY locked_at DESC LIMIT 1`
+	rows, err := r.db.Query(query, models.WebhookStatusProcessing, workerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+	return scanWebhookEvent(rows)
+}
+
+// CompleteEvent releases an event claimed by workerID with its final processing status
+// Returns false when the lease was lost, i.e. the event was re-queued as stale and is no longer locked by workerID
+func (r *WebhookEventRepository) CompleteEvent(id int64, workerID, processingStatus, processingMessage string) (bool, error) {
+	query := `UPDATE webhook_events SET processing_status = ?, processing_message = NULLIF(?, ''), locked_by = NULL, locked_at = NULL, updated_at = ? 
+		WHERE id = ? AND processing_status = ? AND locked_by = ?`
+	result, err := r.db.Exec(query, processingStatus, processingMessage, time.Now(), id, models.WebhookStatusProcessing, workerID)
+	return ownedUpdate(result, err)
+}
+
+// RetryEvent puts an event claimed by workerID back in the queue, due again at availableAt
+// Returns false when the lease was lost
+func (r *WebhookEventRepository) RetryEvent(id int64, workerID, processingMessage string, availableAt time.Time) (bool, error) {
+	query := `UPDATE webhook_events SET processing_status = ?, processing_message = NULLIF(?, ''), available_at = ?, locked_by = NULL, locked_at = NULL, updated_at = ? 
+		WHERE id = ? AND processing_status = ? AND locked_by = ?`
+	result, err := r.db.Exec(query, models.WebhookStatusQueued, processingMessage, availableAt, time.Now(), id, models.WebhookStatusProcessing, workerID)
+	return ownedUpdate(result, err)
+}
+
+// ownedUpdate reports whether a lease guarded update matched the event
+func ownedUpdate(result sql.Result, err error) (bool, error) {
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+// RequeueEvent puts a held or failed event back in the queue with a fresh attempt count
+// amountPolicy overrides AMOUNT_MISMATCH_POLICY for this event only; empty clears a previous override
+// Returns false when the event does not exist or is in another status
+func (r *WebhookEventRepository) RequeueEvent(id int64, amountPolicy string) (bool, error) {
+	query := `UPDATE webhook_events SET processing_status = ?, amount_policy = NULLIF(?, ''), attempts = 0, available_at = ?, updated_at = ? 
+		WHERE id = ? AND processing_status IN (?, ?)`
+
+	now := time.Now()
+	result, err := r.db.Exec(query, models.WebhookStatusQueued, amountPolicy, now, now, id, models.WebhookStatusHeld, models.WebhookStatusFailed)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+// ReleaseStaleEvents re-queues events left in processing by a worker that stopped (e.g. restart)
+// lockedBefore must leave the processing timeout behind, otherwise an event still being processed is claimed twice
+func (r *WebhookEventRepository) ReleaseStaleEvents(lockedBefore time.Time) (int64, error) {
+	query := `UPDATE webhook_events SET processing_status = ?, locked_by = NULL, locked_at = NULL, available_at = ?, updated_at = ? 
+		WHERE processing_status = ? AND locked_at < ?`
+
+	now := time.Now()
+	result, err := r.db.Exec(query, models.WebhookStatusQueued, now, now, models.WebhookStatusProcessing, lockedBefore)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// scanWebhookEvent scans one row selected with webhookEventColumns
+func scanWebhookEvent(rows *sql.Rows) (*models.WebhookEvent, error) {
+	var event models.WebhookEvent
+	var partnerReference, processingMessage, eventType, eventStatus, eventTime, method, amountPolicy, lockedBy sql.NullString
+	if err := rows.Scan(
+		&event.ID,
+		&event.Provider,
+		&event.Channel,
+		&event.Route,
+		&event.SourceIP,
+		&event.Headers,
+		&event.Body,
+		&partnerReference,
+		&event.VerificationStatus,
+		&event.ProcessingStatus,
+		&processingMessage,
+		&eventType,
+		&eventStatus,
+		&event.Amount,
+		&eventTime,
+		&method,
+		&amountPolicy,
+		&event.Attempts,
+		&event.AvailableAt,
+		&lockedBy,
+		&event.LockedAt,
+		&event.CreatedAt,
+		&event.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	event.PartnerReference = nullStringPtr(partnerReference)
+	event.ProcessingMessage = nullStringPtr(processingMessage)
+	event.EventType = nullStringPtr(eventType)
+	event.EventStatus = nullStringPtr(eventStatus)
+	event.EventTime = nullStringPtr(eventTime)
+	event.Method = nullStringPtr(method)
+	event.AmountPolicy = nullStringPtr(amountPolicy)
+	event.LockedBy = nullStringPtr(lockedBy)
+
+	return &event, nil
+}
+
+// nullStringPtr converts sql.NullString to *string
+func nullStringPtr(value sql.NullString) *string {
+	if !value.Valid {
+		return nil
+	}
+	return &value.String
+}
@@ -0,0 +1,68 @@
+package repositories
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"time"
+)
+
+type WebhookNonceRepository struct {
+	db DBTX
+}
+
+func NewWebhookNonceRepository(db *sql.DB) *WebhookNonceRepository {
+	return &WebhookNonceRepository{db: db}
+}
+
+// WithTx returns a copy of the repository bound to the given transaction
+func (r *WebhookNonceRepository) WithTx(tx *sql.Tx) *WebhookNonceRepository {
+	return &WebhookNonceRepository{db: tx}
+}
+
+// Remember stores a provider reference + signature pair
+// Returns false when the pair was already seen and has not expired yet (a replay)
+func (r *WebhookNonceRepository) Remember(provider, reference, signature string, expiresAt time.Time) (bool, error) {
+	now := time.Now()
+	signatureHash := hashSignature(signature)
+
+	// An expired nonce no longer blocks the pair
+	if _, err := r.db.Exec(`DELETE FROM webhook_nonces WHERE provider = ? AND reference = ? AND signature_hash = ? AND expires_at < ?`,
+		provider, reference, signatureHash, now); err != nil {
+		return false, err
+	}
+
+	result, err := r.db.Exec(`INSERT IGNORE INTO webhook_nonces (provider, reference, signature_hash, expires_at, created_at) VALUES (?, ?, ?, ?, ?)`,
+		provider, reference, signatureHash, expiresAt, now)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows == 1, nil
+}
+
+// Forget removes a stored pair, e.g. when the callback could not be queued and the provider will retry
+func (r *WebhookNonceRepository) Forget(provider, reference, signature string) error {
+	_, err := r.db.Exec(`DELETE FROM webhook_nonces WHERE provider = ? AND reference = ? AND signature_hash = ?`,
+		provider, reference, hashSignature(signature))
+	return err
+}
+
+// DeleteExpired removes nonces that expired before now
+func (r *WebhookNonceRepository) DeleteExpired(now time.Time) (int64, error) {
+	result, err := r.db.Exec(`DELETE FROM webhook_nonces WHERE expires_at < ? LIMIT 1000`, now)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// hashSignature keeps the key short; RSA signatures are several hundred characters
+func hashSignature(signature string) string {
+	hash := sha256.Sum256([]byte(signature))
+	return hex.EncodeToString(hash[:])
+}
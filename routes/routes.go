@@ -1,12 +1,18 @@
 package routes
 
 import (
+	"expvar"
+	"strings"
+
 	"github.com/gin-gonic/gin"
+	"github.com/kytapay/webhook-v2/config"
 	"github.com/kytapay/webhook-v2/controllers"
+	"github.com/kytapay/webhook-v2/middlewares"
+	"github.com/kytapay/webhook-v2/providers"
 )
 
 // SetupRoutes configures all routes for the webhook service
-func SetupRoutes(r *gin.Engine, webhookController *controllers.WebhookController) {
+func SetupRoutes(r *gin.Engine, webhookController *controllers.WebhookController, adminController *controllers.AdminController, snapController *controllers.SnapController) {
 	// Health check (support both GET and HEAD for Docker healthcheck)
 	r.GET("/health", func(c *gin.Context) {
 		c.JSON(200, gin.H{
@@ -18,18 +24,31 @@ func SetupRoutes(r *gin.Engine, webhookController *controllers.WebhookController
 		c.Status(200)
 	})
 
-	// Webhook routes
+	// Webhook routes; source IP allowlists (<PROVIDER>_ALLOWED_IPS) are checked by HandleProvider after the
+	// callback is stored, so rejected callbacks are kept in webhook_events as well
 	payments := r.Group("/payments")
 	{
 		linkqu := payments.Group("/linkqu")
 		{
-			linkqu.POST("/qris", webhookController.HandleLinkQuQRIS)
-			linkqu.POST("/ewallet", webhookController.HandleLinkQuEWallet)
+			linkqu.POST("/qris", webhookController.HandleProvider(providers.NewLinkQu(providers.ChannelQRIS)))
+			linkqu.POST("/ewallet", webhookController.HandleProvider(providers.NewLinkQu(providers.ChannelEWallet)))
 		}
 
 		pakailink := payments.Group("/pakailink")
 		{
-			pakailink.POST("/va", webhookController.HandlePakaiLinkVA)
+			pakailink.POST("/va", webhookController.HandleProvider(providers.NewPakaiLink(providers.ChannelVA)))
+		}
+
+		xendit := payments.Group("/xendit")
+		{
+			xendit.POST("/invoice", webhookController.HandleProvider(providers.NewXendit(providers.ChannelInvoice)))
+			xendit.POST("/va", webhookController.HandleProvider(providers.NewXendit(providers.ChannelVA)))
+			xendit.POST("/qris", webhookController.HandleProvider(providers.NewXendit(providers.ChannelQRIS)))
+		}
+
+		midtrans := payments.Group("/midtrans")
+		{
+			midtrans.POST("/notification", webhookController.HandleProvider(providers.NewMidtrans()))
 		}
 	}
 
@@ -38,15 +57,38 @@ func SetupRoutes(r *gin.Engine, webhookController *controllers.WebhookController
 	{
 		linkqu := payouts.Group("/linkqu")
 		{
-			linkqu.POST("/bank", webhookController.HandleLinkQuPayoutBank)
-			linkqu.POST("/ewallet", webhookController.HandleLinkQuPayoutEWallet)
+			linkqu.POST("/bank", webhookController.HandleProvider(providers.NewLinkQu(providers.ChannelPayoutBank)))
+			linkqu.POST("/ewallet", webhookController.HandleProvider(providers.NewLinkQu(providers.ChannelPayoutEWallet)))
 		}
 
 		pakailink := payouts.Group("/pakailink")
 		{
-			pakailink.POST("/bank", webhookController.HandlePakaiLinkPayoutBank)
-			pakailink.POST("/ewallet", webhookController.HandlePakaiLinkPayoutEWallet)
+			pakailink.POST("/bank", webhookController.HandleProvider(providers.NewPakaiLink(providers.ChannelPayoutBank)))
+			pakailink.POST("/ewallet", webhookController.HandleProvider(providers.NewPakaiLink(providers.ChannelPayoutEWallet)))
+		}
+
+		xendit := payouts.Group("/xendit")
+		{
+			xendit.POST("/disbursement", webhookController.HandleProvider(providers.NewXendit(providers.ChannelPayoutBank)))
 		}
 	}
-}
 
+	// SNAP BI partners (SNAP_PARTNERS), e.g. POST /snap/doku/v1.0/transfer-va/payment
+	// The inquiry is answered synchronously, the payment notification goes through the webhook inbox
+	snapRoutes := r.Group("/snap")
+	for code := range config.GetSnapPartners() {
+		partnerGroup := snapRoutes.Group("/" + strings.ToLower(code))
+		{
+			partnerGroup.POST("/v1.0/transfer-va/inquiry", snapController.VAInquiry(code))
+			partnerGroup.POST("/v1.0/transfer-va/payment", webhookController.HandleProvider(providers.NewSnapVA(code)))
+		}
+	}
+
+	// Admin routes (Authorization: Bearer <ADMIN_API_TOKEN>)
+	admin := r.Group("/admin", middlewares.AdminAuth())
+	{
+		admin.POST("/callbacks/:transaction_info_id/resend", adminController.ResendCallback)
+		admin.POST("/webhook-events/:webhook_event_id/requeue", adminController.RequeueWebhookEvent)
+		admin.GET("/metrics", gin.WrapH(expvar.Handler()))
+	}
+}
@@ -0,0 +1,299 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kytapay/webhook-v2/config"
+	"github.com/kytapay/webhook-v2/models"
+	"github.com/kytapay/webhook-v2/repositories"
+)
+
+// responseSnippetLimit caps how much of the merchant response is kept per attempt
+const responseSnippetLimit = 2048
+
+// CallbackDeliveryService delivers merchant callbacks, records the result in callback_status
+// and re-delivers failed callbacks on an exponential backoff schedule
+type CallbackDeliveryService struct {
+	callbackRepo    *repositories.CallbackRepository
+	attemptRepo     *repositories.CallbackAttemptRepository
+	transactionRepo *repositories.TransactionRepository
+	merchantRepo    *repositories.MerchantRepository
+	callbackService *CallbackService
+	telegramService *TelegramService
+	config          *config.CallbackRetryConfig
+	wg              sync.WaitGroup
+}
+
+func NewCallbackDeliveryService(db *sql.DB) *CallbackDeliveryService {
+	return &CallbackDeliveryService{
+		callbackRepo:    repositories.NewCallbackRepository(db),
+		attemptRepo:     repositories.NewCallbackAttemptRepository(db),
+		transactionRepo: repositories.NewTransactionRepository(db),
+		merchantRepo:    repositories.NewMerchantRepository(db),
+		callbackService: NewCallbackService(),
+		telegramService: NewTelegramService(),
+		config:          config.GetCallbackRetryConfig(),
+	}
+}
+
+// Deliver sends the first callback for a transaction; a failed delivery is scheduled for retry
+// Does nothing when the transaction has no callback_status row
+func (ds *CallbackDeliveryService) Deliver(transaction *models.TransactionInfo, payload interface{}) {
+	callback, err := ds.callbackRepo.GetCallbackByTransactionInfoID(transaction.ID)
+	if err != nil {
+		return
+	}
+
+	_, _ = ds.deliver(callback, 1, transaction, payload, true)
+}
+
+// Resend delivers a callback again on request (e.g. from the admin API) and returns the updated
+// callback_status; a failed resend is not retried automatically
+func (ds *CallbackDeliveryService) Resend(transaction *models.TransactionInfo, payload interface{}) (*models.CallbackStatus, error) {
+	callback, err := ds.callbackRepo.GetCallbackByTransactionInfoID(transaction.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	attempts, err := ds.attemptRepo.CountAttemptsByTransactionInfoID(transaction.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := ds.deliver(callback, attempts+1, transaction, payload, false); err != nil {
+		return nil, err
+	}
+	return ds.callbackRepo.GetCallbackByTransactionInfoID(transaction.ID)
+}
+
+// deliver sends the callback to transaction.NotifyURL and stores the result in callback_status
+func (ds *CallbackDeliveryService) deliver(callback *models.CallbackStatus, attemptNumber int, transaction *models.TransactionInfo, payload interface{}, scheduleRetry bool) (string, error) {
+	status, errorMessage, responseBody, err := ds.send(callback, attemptNumber, transaction.NotifyURL, payload, transaction.Token)
+	if err != nil {
+		ds.sendAlert(fmt.Sprintf("❌ <b>Request Timeout (Callback)</b>\n\n• Error: <code>%s</code>\n• URL: <code>%s</code>", err.Error(), transaction.NotifyURL))
+	}
+
+	var nextRetryAt *time.Time
+	if scheduleRetry && status == models.CallbackStatusFailed && ds.config.MaxRetries > 0 {
+		next := time.Now().Add(ds.backoff(0))
+		nextRetryAt = &next
+	}
+
+	return status, ds.callbackRepo.UpdateCallback(transaction.ID, status, errorMessage, responseBody, payload, nextRetryAt)
+}
+
+// Start runs the retry scheduler until ctx is cancelled
+func (ds *CallbackDeliveryService) Start(ctx context.Context) {
+	ds.wg.Add(1)
+	go func() {
+		defer ds.wg.Done()
+
+		ticker := time.NewTicker(ds.config.PollInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				ds.retryDue(ctx)
+			}
+		}
+	}()
+
+	log.Printf("Callback retry scheduler started (max %d retries)", ds.config.MaxRetries)
+}
+
+// Wait blocks until the retry scheduler has stopped
+func (ds *CallbackDeliveryService) Wait() {
+	ds.wg.Wait()
+}
+
+// retryDue re-delivers every callback whose next_retry_at has passed
+func (ds *CallbackDeliveryService) retryDue(ctx context.Context) {
+	callbacks, err := ds.callbackRepo.GetDueRetries(time.Now(), 50)
+	if err != nil {
+		log.Printf("Callback retry: failed to load due callbacks: %v", err)
+		return
+	}
+
+	for i := range callbacks {
+		if ctx.Err() != nil {
+			return
+		}
+		ds.retry(&callbacks[i])
+	}
+}
+
+// retry re-delivers one stored callback payload
+func (ds *CallbackDeliveryService) retry(callback *models.CallbackStatus) {
+	claimed, err := ds.callbackRepo.ClaimRetry(callback.ID, time.Now().Add(ds.config.LeaseTimeout))
+	if err != nil || claimed == nil {
+		return
+	}
+	// Deliver the row as claimed, not the due list entry which may predate a newer notification
+	callback = claimed
+	lease := *claimed.NextRetryAt
+
+	// Token may have been rotated since the first attempt, always read the current one
+	var token *string
+	if transaction, err := ds.transactionRepo.GetTransactionByID(callback.TransactionInfoID); err == nil {
+		token = transaction.Token
+	}
+
+	var payload json.RawMessage
+	if callback.Payload != nil {
+		payload = json.RawMessage(*callback.Payload)
+	}
+
+	// Attempt 1 was the first delivery, retry n is attempt n+1
+	attempt := callback.RetryCount + 1
+	status, errorMessage, responseBody, _ := ds.send(callback, attempt+1, callback.NotifyURL, payload, token)
+
+	var nextRetryAt *time.Time
+	exhausted := false
+	if status == models.CallbackStatusFailed {
+		if attempt < ds.config.MaxRetries {
+			next := time.Now().Add(ds.backoff(attempt))
+			nextRetryAt = &next
+		} else {
+			exhausted = true
+		}
+	}
+
+	updated, err := ds.callbackRepo.UpdateRetry(callback.ID, lease, status, errorMessage, responseBody, nextRetryAt)
+	if err != nil {
+		log.Printf("Callback retry: failed to update callback %d: %v", callback.ID, err)
+		return
+	}
+	if !updated {
+		log.Printf("Callback retry: callback %d changed while retrying, result of attempt %d discarded", callback.ID, attempt+1)
+		return
+	}
+
+	if exhausted {
+		ds.sendAlert(fmt.Sprintf("❌ <b>Callback Retries Exhausted</b>\n\n• Transaction Info ID: %d\n• Merchant ID: %d\n• URL: <code>%s</code>\n• Attempts: %d\n• Last Error: <code>%s</code>", callback.TransactionInfoID, callback.MerchantID, callback.NotifyURL, attempt+1, errorMessage))
+	}
+}
+
+// send makes one delivery attempt, stores it in callback_attempts and maps the result
+// to a callback_status status and message
+func (ds *CallbackDeliveryService) send(callback *models.CallbackStatus, attemptNumber int, url string, payload interface{}, token *string) (string, string, string, error) {
+	result := ds.callbackService.Send(url, payload, token, ds.signingSecret(callback))
+	ds.recordAttempt(callback, attemptNumber, url, result)
+
+	statusCode, responseBody, err := result.StatusCode, result.ResponseBody, result.Err
+	if err != nil {
+		errorMessage := "408 - Request Timeout"
+		if err.Error() != "" {
+			errorMessage = err.Error()
+		}
+		return models.CallbackStatusFailed, errorMessage, responseBody, err
+	}
+
+	if statusCode >= 200 && statusCode < 300 {
+		return models.CallbackStatusSuccess, fmt.Sprintf("%d - Success", statusCode), responseBody, nil
+	}
+
+	errorMessage := fmt.Sprintf("HTTP %d", statusCode)
+	switch statusCode {
+	case 400:
+		errorMessage = "400 - Bad Request"
+	case 401:
+		errorMessage = "401 - Unauthorized"
+	case 403:
+		errorMessage = "403 - Forbidden"
+	case 404:
+		errorMessage = "404 - Not Found"
+	case 422:
+		errorMessage = "422 - Unprocessable Entity"
+	case 500:
+		errorMessage = "500 - Internal Server Error"
+	case 502:
+		errorMessage = "502 - Bad Gateway"
+	case 503:
+		errorMessage = "503 - Service Unavailable"
+	}
+	return models.CallbackStatusFailed, errorMessage, responseBody, nil
+}
+
+// signingSecret returns the merchant's callback secret; callbacks go out unsigned when it is not set
+// Read on every attempt so a rotated secret applies to pending retries too
+func (ds *CallbackDeliveryService) signingSecret(callback *models.CallbackStatus) *string {
+	merchant, err := ds.merchantRepo.GetMerchantByID(callback.MerchantID)
+	if err != nil {
+		log.Printf("Callback delivery: failed to load merchant %d for signing: %v", callback.MerchantID, err)
+		return nil
+	}
+	return merchant.CallbackSecret
+}
+
+// recordAttempt stores one delivery try; the callback token header is masked
+func (ds *CallbackDeliveryService) recordAttempt(callback *models.CallbackStatus, attemptNumber int, url string, result *CallbackResult) {
+	headers := make(map[string]string, len(result.RequestHeaders))
+	for name, values := range result.RequestHeaders {
+		value := strings.Join(values, ", ")
+		if strings.EqualFold(name, "X-CALLBACK-TOKEN") {
+			value = "***"
+		}
+		headers[name] = value
+	}
+	headersJSON, _ := json.Marshal(headers)
+
+	attempt := &models.CallbackAttempt{
+		CallbackStatusID:  callback.ID,
+		TransactionInfoID: callback.TransactionInfoID,
+		AttemptNumber:     attemptNumber,
+		URL:               url,
+		RequestHeaders:    string(headersJSON),
+		RequestPayload:    string(result.RequestBody),
+		DurationMs:        result.Duration.Milliseconds(),
+		ErrorClass:        result.ErrorClass(),
+	}
+	if result.StatusCode != 0 {
+		attempt.HTTPStatus = &result.StatusCode
+	}
+	if result.ResponseBody != "" {
+		snippet := result.ResponseBody
+		if len(snippet) > responseSnippetLimit {
+			snippet = snippet[:responseSnippetLimit]
+		}
+		attempt.ResponseSnippet = &snippet
+	}
+	if result.Err != nil {
+		errorMessage := result.Err.Error()
+		attempt.ErrorMessage = &errorMessage
+	}
+
+	if err := ds.attemptRepo.CreateAttempt(attempt); err != nil {
+		log.Printf("Callback delivery: failed to record attempt for transaction info %d: %v", callback.TransactionInfoID, err)
+	}
+}
+
+// backoff returns the delay before retry number attempt+1: BaseDelay * 2^attempt,
+// capped at MaxDelay, with up to ±20% jitter so merchants are not hit in bursts
+func (ds *CallbackDeliveryService) backoff(attempt int) time.Duration {
+	delay := ds.config.BaseDelay
+	for i := 0; i < attempt && delay < ds.config.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > ds.config.MaxDelay {
+		delay = ds.config.MaxDelay
+	}
+
+	jitter := time.Duration(rand.Int63n(int64(delay)/5+1)) * 2
+	return delay - delay/5 + jitter
+}
+
+// sendAlert sends alert to Telegram
+func (ds *CallbackDeliveryService) sendAlert(message string) {
+	_ = ds.telegramService.SendMessage(message, "HTML")
+}
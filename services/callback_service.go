@@ -3,12 +3,15 @@ package services
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"time"
 
 	"github.com/kytapay/webhook-v2/models"
+	"github.com/kytapay/webhook-v2/pkg/callbacksig"
 )
 
 type CallbackService struct {
@@ -23,16 +26,65 @@ func NewCallbackService() *CallbackService {
 	}
 }
 
+// CallbackResult describes one callback delivery try
+type CallbackResult struct {
+	RequestHeaders http.Header
+	RequestBody    []byte
+	StatusCode     int
+	ResponseBody   string
+	Duration       time.Duration
+	Err            error
+}
+
+// ErrorClass classifies the result for callback delivery history
+func (r *CallbackResult) ErrorClass() string {
+	if r.Err != nil {
+		var netErr net.Error
+		switch {
+		case r.StatusCode != 0:
+			return models.CallbackErrorConnection
+		case errors.As(r.Err, &netErr) && netErr.Timeout():
+			return models.CallbackErrorTimeout
+		case r.RequestBody == nil:
+			return models.CallbackErrorRequest
+		default:
+			return models.CallbackErrorConnection
+		}
+	}
+
+	switch {
+	case r.StatusCode >= 200 && r.StatusCode < 300:
+		return models.CallbackErrorNone
+	case r.StatusCode >= 400 && r.StatusCode < 500:
+		return models.CallbackErrorHTTP4xx
+	case r.StatusCode >= 500:
+		return models.CallbackErrorHTTP5xx
+	default:
+		return models.CallbackErrorHTTPOther
+	}
+}
+
 // SendCallback sends callback to merchant
-func (cs *CallbackService) SendCallback(url string, payload interface{}, token *string) (int, string, error) {
+func (cs *CallbackService) SendCallback(url string, payload interface{}, token, secret *string) (int, string, error) {
+	result := cs.Send(url, payload, token, secret)
+	return result.StatusCode, result.ResponseBody, result.Err
+}
+
+// Send sends callback to merchant and returns the full request/response details
+// When secret is set the body is signed with X-Signature/X-Timestamp (see pkg/callbacksig)
+func (cs *CallbackService) Send(url string, payload interface{}, token, secret *string) *CallbackResult {
+	result := &CallbackResult{}
+
 	jsonData, err := json.Marshal(payload)
 	if err != nil {
-		return 0, "", err
+		result.Err = err
+		return result
 	}
 
 	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
-		return 0, "", err
+		result.Err = err
+		return result
 	}
 
 	req.Header.Set("Content-Type", "application/json")
@@ -43,18 +95,31 @@ func (cs *CallbackService) SendCallback(url string, payload interface{}, token *
 		req.Header.Set("X-CALLBACK-TOKEN", *token)
 	}
 
+	if secret != nil && *secret != "" {
+		timestamp := callbacksig.Timestamp(time.Now())
+		req.Header.Set(callbacksig.HeaderTimestamp, timestamp)
+		req.Header.Set(callbacksig.HeaderSignature, callbacksig.Sign(*secret, timestamp, jsonData))
+	}
+
+	result.RequestHeaders = req.Header.Clone()
+	result.RequestBody = jsonData
+
+	start := time.Now()
 	resp, err := cs.client.Do(req)
 	if err != nil {
-		return 0, "", err
+		result.Duration = time.Since(start)
+		result.Err = err
+		return result
 	}
 	defer resp.Body.Close()
 
+	result.StatusCode = resp.StatusCode
 	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return resp.StatusCode, "", err
-	}
+	result.Duration = time.Since(start)
+	result.ResponseBody = string(body)
+	result.Err = err
 
-	return resp.StatusCode, string(body), nil
+	return result
 }
 
 // BuildPayloadV2 builds payload for version 2
@@ -66,7 +131,7 @@ func BuildPayloadV2(transaction *models.TransactionInfo, paymentID, merchantName
 			"callback_data": map[string]interface{}{
 				"id":           paymentID,
 				"reference_id": transaction.OrderID,
-				"amount":       int(transaction.Amount),
+				"amount":       transaction.Amount.Rupiah(),
 				"status":       status,
 				"payment_type": "QR",
 				"payment_data": map[string]interface{}{
@@ -86,7 +151,7 @@ func BuildPayloadV2(transaction *models.TransactionInfo, paymentID, merchantName
 			"callback_data": map[string]interface{}{
 				"id":           paymentID,
 				"reference_id": transaction.OrderID,
-				"amount":       int(transaction.Amount),
+				"amount":       transaction.Amount.Rupiah(),
 				"status":       status,
 				"payment_type": "VIRTUAL_ACCOUNT",
 				"payment_data": map[string]interface{}{
@@ -108,7 +173,7 @@ func BuildPayloadV2(transaction *models.TransactionInfo, paymentID, merchantName
 			"callback_data": map[string]interface{}{
 				"id":           paymentID,
 				"reference_id": transaction.OrderID,
-				"amount":       int(transaction.Amount),
+				"amount":       transaction.Amount.Rupiah(),
 				"status":       status,
 				"payment_type": "E-WALLET",
 				"payment_data": map[string]interface{}{
@@ -137,7 +202,7 @@ func BuildPayloadV2Payout(transaction *models.TransactionInfo, paymentID, status
 			"callback_data": map[string]interface{}{
 				"id":           paymentID,
 				"reference_id": transaction.OrderID,
-				"amount":       int(transaction.Amount),
+				"amount":       transaction.Amount.Rupiah(),
 				"status":       status,
 				"payout_data": map[string]interface{}{
 					"code":          transaction.PaymentMethod,
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/kytapay/webhook-v2/config"
+)
+
+// envFile is the dotenv file loaded at startup and re-read on reload
+const envFile = ".env"
+
+// CredentialReloader reloads provider credentials and key files without a restart,
+// on SIGHUP or when .env or a configured key file changes on disk
+type CredentialReloader struct {
+	interval        time.Duration
+	telegramService *TelegramService
+	modTimes        map[string]time.Time
+	wg              sync.WaitGroup
+}
+
+func NewCredentialReloader() *CredentialReloader {
+	return &CredentialReloader{
+		interval:        config.GetCredentialReloadInterval(),
+		telegramService: NewTelegramService(),
+		modTimes:        make(map[string]time.Time),
+	}
+}
+
+// Start watches for SIGHUP and file changes until ctx is cancelled
+func (cr *CredentialReloader) Start(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	cr.filesChanged()
+
+	cr.wg.Add(1)
+	go func() {
+		defer cr.wg.Done()
+		defer signal.Stop(hup)
+
+		ticker := time.NewTicker(cr.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+				cr.reload("SIGHUP")
+			case <-ticker.C:
+				if cr.filesChanged() {
+					cr.reload("file change")
+				}
+			}
+		}
+	}()
+
+	log.Printf("Credential reloader started (SIGHUP, file check every %s)", cr.interval)
+}
+
+// Wait blocks until the reloader has stopped
+func (cr *CredentialReloader) Wait() {
+	cr.wg.Wait()
+}
+
+// reload re-reads .env and the provider credentials; a failed reload keeps the previous credentials
+// .env is read as a whole, so a variable removed from it (e.g. LINKQU_NEXT_* after a rotation) stops being accepted
+func (cr *CredentialReloader) reload(reason string) {
+	if _, err := os.Stat(envFile); err == nil {
+		if err := config.LoadEnvFile(envFile); err != nil {
+			log.Printf("Credential reload (%s): failed to read %s: %v", reason, envFile, err)
+		}
+	}
+
+	if err := config.ReloadProviderCredentials(); err != nil {
+		log.Printf("Credential reload (%s) failed, previous credentials kept: %v", reason, err)
+		_ = cr.telegramService.SendMessage(fmt.Sprintf("❌ <b>Credential Reload Failed</b>\n\n• Trigger: %s\n• Error: <code>%s</code>\n• Action: Previous credentials kept", reason, err.Error()), "HTML")
+		return
+	}
+
+	// Key paths may have changed, start tracking the new set
+	cr.filesChanged()
+	log.Printf("Credential reload (%s): provider credentials reloaded", reason)
+}
+
+// filesChanged records the modification times of .env and the key files
+// and reports whether any of them differ from the previous check
+func (cr *CredentialReloader) filesChanged() bool {
+	files := append([]string{envFile}, config.CredentialFiles()...)
+	current := make(map[string]time.Time, len(files))
+	for _, file := range files {
+		if info, err := os.Stat(file); err == nil {
+			current[file] = info.ModTime()
+		}
+	}
+
+	changed := len(current) != len(cr.modTimes)
+	for file, modTime := range current {
+		if previous, ok := cr.modTimes[file]; !ok || !previous.Equal(modTime) {
+			changed = true
+		}
+	}
+
+	cr.modTimes = current
+	return changed
+}
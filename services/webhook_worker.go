@@ -0,0 +1,225 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/kytapay/webhook-v2/config"
+	"github.com/kytapay/webhook-v2/models"
+	"github.com/kytapay/webhook-v2/repositories"
+)
+
+// ErrEventIgnored can be wrapped by a WebhookEventHandler to finish an event as ignored
+// instead of failed (e.g. duplicate callbacks); ignored events are never retried
+var ErrEventIgnored = errors.New("event ignored")
+
+// ErrEventHeld is wrapped by handlers for events that need manual review; the event is not retried
+var ErrEventHeld = errors.New("event held for review")
+
+// WebhookEventHandler runs the settlement pipeline for one queued event
+// ctx expires after WorkerConfig.ProcessTimeout; database transactions begun with it are rolled back then
+type WebhookEventHandler func(ctx context.Context, event *models.WebhookEvent) error
+
+// WebhookWorker processes queued webhook_events with a fixed pool of goroutines
+// The queue lives in the database, so queued events survive restarts
+type WebhookWorker struct {
+	repo            *repositories.WebhookEventRepository
+	nonceRepo       *repositories.WebhookNonceRepository
+	externalIDRepo  *repositories.SnapExternalIDRepository
+	config          *config.WorkerConfig
+	handler         WebhookEventHandler
+	telegramService *TelegramService
+	wake            chan struct{}
+	wg              sync.WaitGroup
+}
+
+func NewWebhookWorker(db *sql.DB, cfg *config.WorkerConfig, handler WebhookEventHandler) *WebhookWorker {
+	return &WebhookWorker{
+		repo:            repositories.NewWebhookEventRepository(db),
+		nonceRepo:       repositories.NewWebhookNonceRepository(db),
+		externalIDRepo:  repositories.NewSnapExternalIDRepository(db),
+		config:          cfg,
+		handler:         handler,
+		telegramService: NewTelegramService(),
+		wake:            make(chan struct{}, cfg.Concurrency),
+	}
+}
+
+// Start starts the worker goroutines and the stale lock release, which also re-queues events
+// abandoned by a previous run; workers stop when ctx is cancelled, use Wait to block until in-flight events are done
+func (w *WebhookWorker) Start(ctx context.Context) {
+	w.wg.Add(1)
+	go w.releaseStaleEvents(ctx)
+
+	hostname, _ := os.Hostname()
+	for i := 0; i < w.config.Concurrency; i++ {
+		workerID := fmt.Sprintf("%s-%d-%d", hostname, os.Getpid(), i)
+		w.wg.Add(1)
+		go w.run(ctx, workerID)
+	}
+
+	w.wg.Add(1)
+	go w.purgeNonces(ctx)
+
+	log.Printf("Webhook worker started with %d workers", w.config.Concurrency)
+}
+
+// Wait blocks until all worker goroutines have stopped
+func (w *WebhookWorker) Wait() {
+	w.wg.Wait()
+}
+
+// Notify wakes an idle worker so a freshly queued event is picked up without waiting for the next poll
+func (w *WebhookWorker) Notify() {
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run claims and processes events until ctx is cancelled
+func (w *WebhookWorker) run(ctx context.Context, workerID string) {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(w.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		// Drain the queue before going idle
+		for ctx.Err() == nil {
+			event, err := w.repo.ClaimNextEvent(workerID)
+			if err != nil {
+				log.Printf("Webhook worker %s: failed to claim event: %v", workerID, err)
+				break
+			}
+			if event == nil {
+				break
+			}
+			w.process(workerID, event)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-w.wake:
+		}
+	}
+}
+
+// releaseStaleEvents periodically re-queues events left in processing for longer than LockTimeout,
+// e.g. by a hung handler or an instance that died mid-event
+// LockTimeout outlives ProcessTimeout, so the database work of a released event has already been rolled back
+func (w *WebhookWorker) releaseStaleEvents(ctx context.Context) {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(w.config.LockTimeout)
+	defer ticker.Stop()
+
+	for {
+		released, err := w.repo.ReleaseStaleEvents(time.Now().Add(-w.config.LockTimeout))
+		if err != nil {
+			log.Printf("Webhook worker: failed to release stale events: %v", err)
+		} else if released > 0 {
+			log.Printf("Webhook worker: re-queued %d stale events", released)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// purgeNonces periodically removes expired replay cache entries and SNAP external ids of past days
+// (yesterday's are kept, partners may send X-TIMESTAMP in another timezone around midnight)
+func (w *WebhookWorker) purgeNonces(ctx context.Context) {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		if _, err := w.nonceRepo.DeleteExpired(time.Now()); err != nil {
+			log.Printf("Webhook worker: failed to purge expired nonces: %v", err)
+		}
+		if _, err := w.externalIDRepo.DeleteBefore(time.Now().AddDate(0, 0, -1).Format("2006-01-02")); err != nil {
+			log.Printf("Webhook worker: failed to purge SNAP external ids: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// process runs the handler and records the outcome, scheduling a retry on failure
+func (w *WebhookWorker) process(workerID string, event *models.WebhookEvent) {
+	ctx, cancel := context.WithTimeout(context.Background(), w.config.ProcessTimeout)
+	err := w.safeHandle(ctx, event)
+	cancel()
+
+	switch {
+	case err == nil:
+		w.complete(workerID, event, models.WebhookStatusProcessed, "")
+	case errors.Is(err, ErrEventIgnored):
+		w.complete(workerID, event, models.WebhookStatusIgnored, err.Error())
+	case errors.Is(err, ErrEventHeld):
+		w.complete(workerID, event, models.WebhookStatusHeld, err.Error())
+	case event.Attempts >= w.config.MaxAttempts:
+		if !w.complete(workerID, event, models.WebhookStatusFailed, err.Error()) {
+			return
+		}
+		_ = w.telegramService.SendMessage(fmt.Sprintf("❌ <b>Webhook Event Failed</b>\n\n• Event ID: %d\n• Source: %s %s\n• Partner Reference: <code>%s</code>\n• Attempts: %d\n• Error: <code>%s</code>", event.ID, event.Channel, event.Provider, stringValue(event.PartnerReference), event.Attempts, err.Error()), "HTML")
+	default:
+		// Linear backoff: 1x, 2x, 3x ... the retry delay
+		availableAt := time.Now().Add(time.Duration(event.Attempts) * w.config.RetryDelay)
+		owned, retryErr := w.repo.RetryEvent(event.ID, workerID, err.Error(), availableAt)
+		if retryErr != nil {
+			log.Printf("Webhook worker: failed to re-queue event %d: %v", event.ID, retryErr)
+		} else if !owned {
+			log.Printf("Webhook worker %s: lost the lease of event %d, retry not recorded", workerID, event.ID)
+		}
+	}
+}
+
+// safeHandle runs the handler, turning a panic into an error so the worker keeps running
+func (w *WebhookWorker) safeHandle(ctx context.Context, event *models.WebhookEvent) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return w.handler(ctx, event)
+}
+
+// complete stores the final status of an event while workerID still holds its lease
+// Returns false when the status was not stored
+func (w *WebhookWorker) complete(workerID string, event *models.WebhookEvent, processingStatus, message string) bool {
+	owned, err := w.repo.CompleteEvent(event.ID, workerID, processingStatus, message)
+	if err != nil {
+		log.Printf("Webhook worker: failed to complete event %d: %v", event.ID, err)
+		return false
+	}
+	if !owned {
+		log.Printf("Webhook worker %s: lost the lease of event %d, %s not recorded", workerID, event.ID, processingStatus)
+	}
+	return owned
+}
+
+// stringValue dereferences an optional string
+func stringValue(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}